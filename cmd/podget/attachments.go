@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Appended to the episode filename, minus its extension, to name its chapters
+// file
+const chaptersSuffix = ".chapters.json"
+
+// transcriptExts maps transcript MIME types to file extensions.
+var transcriptExts = map[string]string{
+	"application/srt":      ".srt",
+	"application/x-subrip": ".srt",
+	"text/srt":             ".srt",
+	"text/vtt":             ".vtt",
+	"application/json":     ".json",
+	"text/html":            ".html",
+	"text/plain":           ".txt",
+}
+
+// transcriptExt returns the file extension to use for a transcript, based on
+// its type or failing that its URL.
+func transcriptExt(tr *podcast.Transcript) string {
+	mimetype := strings.ToLower(strings.TrimSpace(tr.Type))
+	if i := strings.Index(mimetype, ";"); i >= 0 {
+		mimetype = strings.TrimSpace(mimetype[:i])
+	}
+	if ext, ok := transcriptExts[mimetype]; ok {
+		return ext
+	}
+	if u, err := url.Parse(tr.URL); err == nil && path.Ext(u.Path) != "" {
+		return path.Ext(u.Path)
+	}
+	return ".txt"
+}
+
+// episodeBase returns the episode filename with its extension removed, for
+// naming files which accompany the episode.
+func episodeBase(destfile string) string {
+	return strings.TrimSuffix(destfile, filepath.Ext(destfile))
+}
+
+// queueTranscripts queues downloads of the item's transcripts, to be stored
+// alongside the episode file. If -transcript-format is set and a transcript in
+// that format is available, the other formats are skipped.
+func (r *Runner) queueTranscripts(item *podcast.Item, destfile string) {
+	selected := item.Transcripts
+	if r.TranscriptFormat != "" {
+		want := "." + strings.TrimPrefix(strings.ToLower(r.TranscriptFormat), ".")
+		var preferred []*podcast.Transcript
+		for _, tr := range item.Transcripts {
+			if transcriptExt(tr) == want || strings.EqualFold(tr.Type, r.TranscriptFormat) {
+				preferred = append(preferred, tr)
+			}
+		}
+		if len(preferred) > 0 {
+			selected = preferred
+		}
+	}
+	for _, tr := range selected {
+		trfile := episodeBase(destfile) + transcriptExt(tr)
+		if r.needsDownload(trfile, 0) {
+			r.downloads.Queue(tr.URL, trfile)
+		} else {
+			logDebug("skipping transcript %s, already downloaded", trfile)
+		}
+	}
+}
+
+// queueChapters queues a download of the item's chapters file, if it has one,
+// to be stored alongside the episode file.
+func (r *Runner) queueChapters(item *podcast.Item, destfile string) {
+	if item.Chapters == nil || item.Chapters.URL == "" {
+		return
+	}
+	chfile := episodeBase(destfile) + chaptersSuffix
+	if r.needsDownload(chfile, 0) {
+		r.downloads.Queue(item.Chapters.URL, chfile)
+	} else {
+		logDebug("skipping chapters %s, already downloaded", chfile)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Held while deleting files, so that concurrent downloads don't try to clean
+// up the same directory at once
+var cleanupMu sync.Mutex
+
+var pubDatesMu sync.Mutex
+
+// Publication dates of the episodes in the feeds, by destination file
+var pubDates = make(map[string]time.Time)
+
+// recordPubDate remembers the publication date of the episode which is
+// downloaded to file, so that files can be sorted by when they were published
+// rather than when they were downloaded.
+func recordPubDate(file string, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	pubDatesMu.Lock()
+	defer pubDatesMu.Unlock()
+	pubDates[file] = t
+}
+
+// localFile is a file in a feed directory.
+type localFile struct {
+	Path    string
+	Size    int64
+	Date    time.Time // Publication date if known, otherwise modification time
+	ModTime time.Time
+}
+
+// feedFiles returns the regular files in a feed directory, newest first.
+// Hidden files, such as partial downloads, symlinks and copies of the latest
+// episode made by -symlink-latest are ignored.
+func feedFiles(dir string) ([]localFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []localFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || episodeBase(name) == "latest" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := localFile{
+			Path:    filepath.Join(dir, name),
+			Size:    info.Size(),
+			Date:    info.ModTime(),
+			ModTime: info.ModTime(),
+		}
+		pubDatesMu.Lock()
+		if t, ok := pubDates[file.Path]; ok {
+			file.Date = t
+		}
+		pubDatesMu.Unlock()
+		files = append(files, file)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Date.After(files[j].Date)
+	})
+	return files, nil
+}
+
+// removeFile deletes a file to free up space, or just says it would if
+// dryRun is set.
+func removeFile(file string, reason string, dryRun bool) {
+	if dryRun {
+		fmt.Printf("would delete %s, %s\n", file, reason)
+		return
+	}
+	if err := os.Remove(file); err != nil {
+		logError("can't delete %s: %v", file, err)
+		return
+	}
+	fmt.Printf("deleted %s, %s\n", file, reason)
+}
+
+// enforceQuota deletes the oldest files in a feed directory until its total
+// size is within -quota. The newest file is never deleted.
+func (r *Runner) enforceQuota(dir string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	files, err := feedFiles(dir)
+	if err != nil {
+		logError("can't check size of %s: %v", dir, err)
+		return
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	for i := len(files) - 1; i > 0 && total > r.Quota; i-- {
+		removeFile(files[i].Path, fmt.Sprintf("%s is over quota", dir), r.QuotaDryRun)
+		total -= files[i].Size
+	}
+}
+
+// isAttachment reports whether a file is a transcript or chapters file, going
+// by its extension.
+func isAttachment(file string) bool {
+	if strings.HasSuffix(file, chaptersSuffix) {
+		return true
+	}
+	ext := filepath.Ext(file)
+	for _, e := range transcriptExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceKeep deletes all but the -keep-n most recently published episodes in
+// a feed directory, along with their transcripts and chapters. Episodes
+// modified within -keep-grace are kept regardless.
+func (r *Runner) enforceKeep(dir string) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	files, err := feedFiles(dir)
+	if err != nil {
+		logError("can't list files in %s: %v", dir, err)
+		return
+	}
+	kept := 0
+	for _, f := range files {
+		if isAttachment(f.Path) {
+			continue
+		}
+		if kept < r.KeepN || time.Since(f.ModTime) < r.KeepGrace {
+			kept++
+			continue
+		}
+		reason := fmt.Sprintf("-keep-n is %d", r.KeepN)
+		removeFile(f.Path, reason, false)
+		for _, a := range files {
+			if isAttachment(a.Path) && strings.HasPrefix(a.Path, episodeBase(f.Path)+".") {
+				removeFile(a.Path, reason, false)
+			}
+		}
+	}
+}
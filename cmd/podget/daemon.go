@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+type scheduledFeed struct {
+	source  feedSource
+	channel *podcast.Channel
+	next    time.Time
+}
+
+// feedInterval returns how long to wait before fetching a feed again. That's
+// the feed's interval from the -feeds file, or the -interval value if it
+// doesn't have one, unless the feed's TTL asks for a longer wait. Feeds
+// without a TTL can ask for a longer wait using sy:updatePeriod.
+func (r *Runner) feedInterval(src feedSource, channel *podcast.Channel) time.Duration {
+	iv := r.Interval
+	if src.Interval > 0 {
+		iv = src.Interval
+	}
+	if channel != nil {
+		hint := time.Duration(channel.TTL) * time.Minute
+		if hint == 0 {
+			hint = channel.UpdateInterval()
+		}
+		if hint > iv {
+			iv = hint
+		}
+	}
+	return iv
+}
+
+// rescheduleFeeds returns the schedule for a new list of feeds, keeping the
+// next fetch time and last fetched channel of feeds which were already
+// scheduled. Feeds which are no longer listed are dropped.
+func rescheduleFeeds(feeds []*scheduledFeed, sources []feedSource) []*scheduledFeed {
+	type feedID struct{ url, dir string }
+	old := make(map[feedID]*scheduledFeed, len(feeds))
+	for _, feed := range feeds {
+		old[feedID{feed.source.URL, feed.source.Dir}] = feed
+	}
+	rescheduled := make([]*scheduledFeed, len(sources))
+	for i, src := range sources {
+		feed, ok := old[feedID{src.URL, src.Dir}]
+		if !ok {
+			feed = &scheduledFeed{}
+		}
+		feed.source = src
+		rescheduled[i] = feed
+	}
+	return rescheduled
+}
+
+// runDaemon fetches the feeds repeatedly, sleeping until the next one is due.
+// Feeds which ask not to be fetched at the current time via skipHours or
+// skipDays are postponed until the next hour. Sending the process one of the
+// fetchNowSignals makes all feeds due immediately, and if there's a FeedsFile,
+// one of the reloadSignals rereads it along with args to get a new list of
+// feeds. It never returns.
+func (r *Runner) runDaemon(args []string, sources []feedSource) {
+	if len(sources) == 0 {
+		logError("no feeds to fetch")
+		os.Exit(1)
+	}
+	feeds := rescheduleFeeds(nil, sources)
+	fetchNow := make(chan os.Signal, 1)
+	if len(fetchNowSignals) > 0 {
+		signal.Notify(fetchNow, fetchNowSignals...)
+	}
+	reload := make(chan os.Signal, 1)
+	if r.FeedsFile != "" && len(reloadSignals) > 0 {
+		signal.Notify(reload, reloadSignals...)
+	}
+	for {
+		now := time.Now()
+		var due []*scheduledFeed
+		var duesources []feedSource
+		for _, feed := range feeds {
+			if now.Before(feed.next) {
+				continue
+			}
+			if feed.channel != nil && feed.channel.SkipAt(now) {
+				logInfo("not fetching %s, feed asks to be skipped at this time", feed.source.URL)
+				feed.next = now.Truncate(time.Hour).Add(time.Hour)
+				continue
+			}
+			due = append(due, feed)
+			duesources = append(duesources, feed.source)
+		}
+		for i, fetched := range r.fetchFeeds(duesources) {
+			feed := due[i]
+			if fetched.Channel != nil {
+				feed.channel = fetched.Channel
+			}
+			feed.next = time.Now().Add(r.feedInterval(feed.source, feed.channel))
+		}
+		var next time.Time
+		for _, feed := range feeds {
+			if next.IsZero() || feed.next.Before(next) {
+				next = feed.next
+			}
+		}
+		if r.ExportOPML != "" {
+			fetched := make([]fetchedFeed, len(feeds))
+			for i, feed := range feeds {
+				fetched[i] = fetchedFeed{URL: feed.source.URL, Channel: feed.channel}
+			}
+			exportOPMLFile(r.ExportOPML, fetched)
+		}
+		logInfo("next fetch scheduled for %s", next.Format(time.RFC1123))
+		select {
+		case <-time.After(time.Until(next)):
+		case sig := <-fetchNow:
+			logInfo("received %v, fetching all feeds now", sig)
+			for _, feed := range feeds {
+				feed.next = time.Time{}
+			}
+		case sig := <-reload:
+			logInfo("received %v, reloading %s", sig, r.FeedsFile)
+			sources, err := r.feedSources(args)
+			if err != nil {
+				logError("can't reload feeds, keeping the current list: %v", err)
+				continue
+			}
+			if len(sources) == 0 {
+				logError("no feeds to fetch after reloading, keeping the current list")
+				continue
+			}
+			if r.locks != nil {
+				if err := r.locks.lockSources(sources); err != nil {
+					logError("can't reload feeds, keeping the current list: %v", err)
+					continue
+				}
+			}
+			feeds = rescheduleFeeds(feeds, sources)
+			logInfo("%d feeds after reloading", len(feeds))
+		}
+	}
+}
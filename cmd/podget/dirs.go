@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Values accepted by -dir-strategy
+var dirStrategies = []string{"feed", "author", "category", "date", "flat"}
+
+// feedSource is a feed given on the command line or in a -feeds file, along
+// with the directory its episodes are downloaded to.
+type feedSource struct {
+	URL      string
+	Dir      string
+	Interval time.Duration // Overrides -interval in daemon mode if non-zero
+}
+
+// parseFeedArg splits a feed argument of the form URL@dir, where the @ is only
+// treated as a separator if it's followed by an absolute path starting with /
+// or ~. Arguments without a directory use defaultDir.
+func parseFeedArg(arg string, defaultDir string) (feedSource, error) {
+	for i := 0; i < len(arg)-1; i++ {
+		if arg[i] != '@' || (arg[i+1] != '/' && arg[i+1] != '~') {
+			continue
+		}
+		dir := arg[i+1:]
+		if dir == "~" || strings.HasPrefix(dir, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return feedSource{}, fmt.Errorf("can't expand %s: %w", dir, err)
+			}
+			dir = filepath.Join(home, dir[1:])
+		}
+		return feedSource{URL: arg[:i], Dir: filepath.Clean(dir)}, nil
+	}
+	return feedSource{URL: arg, Dir: defaultDir}, nil
+}
+
+// parseFeedArgs parses each of the feed arguments with parseFeedArg.
+func parseFeedArgs(args []string, defaultDir string) ([]feedSource, error) {
+	sources := make([]feedSource, len(args))
+	for i, arg := range args {
+		src, err := parseFeedArg(arg, defaultDir)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = src
+	}
+	return sources, nil
+}
+
+// feedURLs returns the URLs of the feed sources.
+func feedURLs(sources []feedSource) []string {
+	urls := make([]string, len(sources))
+	for i, src := range sources {
+		urls[i] = src.URL
+	}
+	return urls
+}
+
+// feedDir returns the directory for a feed's episodes, relative to -d, for the
+// strategies which use one directory per feed. For the date and flat
+// strategies it returns the feed's slug, which is used to tell feeds apart.
+func (r *Runner) feedDir(channel *podcast.Channel) string {
+	switch r.DirStrategy {
+	case "author":
+		if strings.TrimSpace(channel.Author) != "" {
+			return r.slugify(channel.Author)
+		}
+	case "category":
+		// Subcategories become subdirectories
+		var parts []string
+		if len(channel.Category) > 0 {
+			for cat := channel.Category[0]; cat != nil; cat = cat.Subcategory {
+				if strings.TrimSpace(cat.AttrText) != "" {
+					parts = append(parts, r.slugify(cat.AttrText))
+				}
+			}
+		}
+		if len(parts) == 0 {
+			return "Uncategorized"
+		}
+		return filepath.Join(parts...)
+	}
+	return r.slugify(channel.Title)
+}
+
+// perFeedDirs reports whether each feed's episodes are downloaded to a
+// directory of its own. -keep-n, -quota and -symlink-latest act on every file
+// in an episode's directory, so they need it to be true, otherwise they would
+// delete or link to other feeds' episodes.
+func (r *Runner) perFeedDirs() bool {
+	return r.DirStrategy == "" || r.DirStrategy == "feed"
+}
+
+// episodePath returns the path to download an episode to, given the download
+// directory for the feed, the directory from feedDir and the episode's
+// filename.
+func (r *Runner) episodePath(basedir string, feeddir string, item *podcast.Item, filename string) string {
+	var destfile string
+	filename = r.sanitizeName(filename)
+	switch r.DirStrategy {
+	case "date":
+		dir := "undated"
+		if !item.PubDate.IsZero() {
+			dir = item.PubDate.Format("2006/01")
+		}
+		destfile = filepath.Join(basedir, filepath.FromSlash(dir), filename)
+	case "flat":
+		destfile = filepath.Join(basedir, r.sanitizeName(feeddir+"_"+filename))
+	default:
+		destfile = filepath.Join(basedir, feeddir, filename)
+	}
+	return r.claimPath(destfile, feeddir)
+}
+
+var claimsMu sync.Mutex
+
+// The feed each destination file is being used for, by path
+var claims = make(map[string]string)
+
+// claimPath records that destfile is used for an episode of the given feed.
+// If it's already used by a different feed, which can happen when several
+// feeds share a directory, -conflict-suffix is added to the filename.
+func (r *Runner) claimPath(destfile string, feed string) string {
+	claimsMu.Lock()
+	defer claimsMu.Unlock()
+	if owner, ok := claims[destfile]; ok && owner != feed {
+		ext := filepath.Ext(destfile)
+		suffix := strings.ReplaceAll(r.ConflictSuffix, "{feed}", feed)
+		if suffix == "" {
+			suffix = "_" + feed
+		}
+		name := strings.TrimSuffix(filepath.Base(destfile), ext) + suffix + ext
+		destfile = filepath.Join(filepath.Dir(destfile), r.sanitizeName(name))
+	}
+	claims[destfile] = feed
+	return destfile
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Paths tried when a web page doesn't link to its feed
+var commonFeedPaths = []string{"/feed", "/rss", "/podcast.xml"}
+
+// feedLinks returns the URLs of any RSS or Atom feeds advertised by
+// <link rel="alternate"> tags in the HTML, resolved relative to base.
+func feedLinks(r io.Reader, base *url.URL) ([]string, error) {
+	var links []string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return links, nil
+			}
+			return links, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data != "link" {
+				continue
+			}
+			var rel, ctype, href string
+			for _, attr := range tok.Attr {
+				switch attr.Key {
+				case "rel":
+					rel = strings.ToLower(attr.Val)
+				case "type":
+					ctype = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "href":
+					href = attr.Val
+				}
+			}
+			if !strings.Contains(" "+rel+" ", " alternate ") || href == "" {
+				continue
+			}
+			if ctype != "application/rss+xml" && ctype != "application/atom+xml" {
+				continue
+			}
+			u, err := base.Parse(href)
+			if err != nil {
+				continue
+			}
+			links = append(links, u.String())
+		}
+	}
+}
+
+// looksLikeFeed fetches the URL and reports whether it returns an RSS or Atom
+// document.
+func looksLikeFeed(rawurl string) bool {
+	req, err := newFeedRequest(context.Background(), http.MethodGet, rawurl)
+	if err != nil {
+		return false
+	}
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return false
+	}
+	mediatype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if isFeedType(mediatype) || mediatype == "application/atom+xml" {
+		return true
+	}
+	start, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return strings.Contains(string(start), "<rss") || strings.Contains(string(start), "<feed")
+}
+
+// discoverFeeds returns the feed URLs for a website, found either from link
+// tags in the page or by trying commonFeedPaths.
+func (r *Runner) discoverFeeds(siteurl string) ([]string, error) {
+	base, err := url.Parse(siteurl)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newFeedRequest(context.Background(), http.MethodGet, siteurl)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("server returned HTTP %d for %s", resp.StatusCode, siteurl)
+	}
+	var body io.Reader = resp.Body
+	if r.MaxFeedSize > 0 {
+		body = io.LimitReader(body, r.MaxFeedSize)
+	}
+	links, err := feedLinks(body, resp.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %v", err)
+	}
+	if len(links) > 0 {
+		return links, nil
+	}
+	logDebug("no feed links in %s, trying common paths", siteurl)
+	for _, p := range commonFeedPaths {
+		u, _ := base.Parse(p)
+		if looksLikeFeed(u.String()) {
+			links = append(links, u.String())
+		}
+	}
+	return links, nil
+}
+
+// discover prints the feed URLs found for each website URL.
+func (r *Runner) discover(siteurls []string) {
+	for _, siteurl := range siteurls {
+		links, err := r.discoverFeeds(siteurl)
+		if err != nil {
+			logError("can't discover feeds for %s: %v", siteurl, err)
+			continue
+		}
+		if len(links) == 0 {
+			logError("no feeds found for %s", siteurl)
+		}
+		for _, link := range links {
+			fmt.Println(link)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+type Download struct {
+	URL       string
+	File      string
+	Item      *podcast.Item // The episode, if this is its enclosure
+	Feed      string        // Key of the episode's feed in the GUID database
+	Key       string        // Key of the episode in the GUID database
+	Report    *feedReport
+	Integrity *podcast.Integrity // Hash to check the file against, if known
+}
+
+// Downloader downloads queued files in the background, using a fixed number
+// of workers.
+type Downloader struct {
+	queue     chan *Download
+	fetch     func(*Download)
+	delay     time.Duration
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// newDownloader returns a Downloader with the given number of workers, which
+// are started immediately. Each worker passes downloads to fetch one at a
+// time, pausing for delay in between.
+func newDownloader(workers int, delay time.Duration, fetch func(*Download)) *Downloader {
+	d := &Downloader{queue: make(chan *Download, queueSize), fetch: fetch, delay: delay}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.work()
+		}()
+	}
+	return d
+}
+
+// Queue queues a download of url to file. It blocks if the queue is full.
+func (d *Downloader) Queue(url string, file string) {
+	d.QueueDownload(&Download{URL: url, File: file})
+}
+
+// QueueDownload queues a download. It blocks if the queue is full.
+func (d *Downloader) QueueDownload(dl *Download) {
+	d.queue <- dl
+}
+
+// Close stops the downloader accepting downloads. Downloads already queued
+// are still made. It's safe to call Close more than once.
+func (d *Downloader) Close() {
+	d.closeOnce.Do(func() { close(d.queue) })
+}
+
+// Wait waits for the queued downloads to finish after Close has been called.
+func (d *Downloader) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Downloader) work() {
+	logDebug("download task starting")
+	for dl := range d.queue {
+		d.fetch(dl)
+		// Pause between downloads, but not after the last one
+		if len(d.queue) > 0 {
+			time.Sleep(d.delay)
+		}
+	}
+	logDebug("all downloads complete, download task finishing")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Values accepted by -episode-order
+var episodeOrders = []string{"feed", "newest", "oldest"}
+
+// episodeRange selects episodes by their 1-based position in the feed, as
+// given to -episode-range. If last is zero the range runs to the end of the
+// feed, and if tail is non-zero it selects the last tail episodes instead.
+type episodeRange struct {
+	first int
+	last  int
+	tail  int
+}
+
+// parseEpisodeRange parses a range of the form 50-100, 50- or -10.
+func parseEpisodeRange(s string) (*episodeRange, error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid episode range %s, must be FROM-TO, FROM- or -COUNT", s)
+	}
+	from, to := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if from == "" {
+		tail, err := strconv.Atoi(to)
+		if err != nil || tail < 1 {
+			return nil, fmt.Errorf("invalid episode range %s, count must be a positive number", s)
+		}
+		return &episodeRange{tail: tail}, nil
+	}
+	first, err := strconv.Atoi(from)
+	if err != nil || first < 1 {
+		return nil, fmt.Errorf("invalid episode range %s, episodes are numbered from 1", s)
+	}
+	er := &episodeRange{first: first}
+	if to != "" {
+		er.last, err = strconv.Atoi(to)
+		if err != nil || er.last < first {
+			return nil, fmt.Errorf("invalid episode range %s, end must be a number no less than the start", s)
+		}
+	}
+	return er, nil
+}
+
+// apply returns the items within the range.
+func (er *episodeRange) apply(items []*podcast.Item) []*podcast.Item {
+	if er.tail > 0 {
+		if er.tail >= len(items) {
+			return items
+		}
+		return items[len(items)-er.tail:]
+	}
+	if er.first > len(items) {
+		return nil
+	}
+	end := len(items)
+	if er.last > 0 && er.last < end {
+		end = er.last
+	}
+	return items[er.first-1 : end]
+}
+
+// orderEpisodes returns the items in the order -episode-range counts them in:
+// as they appear in the feed, or sorted by publication date newest or oldest
+// first. The slice passed in isn't modified.
+func orderEpisodes(items []*podcast.Item, order string) []*podcast.Item {
+	if order != "newest" && order != "oldest" {
+		return items
+	}
+	sorted := make([]*podcast.Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if order == "oldest" {
+			return sorted[i].PubDate.Before(sorted[j].PubDate.Time)
+		}
+		return sorted[i].PubDate.After(sorted[j].PubDate.Time)
+	})
+	return sorted
+}
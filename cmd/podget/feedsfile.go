@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// readFeedsFile reads a list of feeds from a file, one per line, in the same
+// URL@dir form as command line arguments. A feed may be followed by an
+// interval such as 12h, which overrides -interval for it in daemon mode.
+// Blank lines and lines starting with # are ignored.
+func readFeedsFile(path string, defaultDir string) ([]feedSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sources []feedSource
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: expected a feed and an optional interval", path, lineno)
+		}
+		src, err := parseFeedArg(fields[0], defaultDir)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+		if len(fields) == 2 {
+			src.Interval, err = time.ParseDuration(fields[1])
+			if err != nil || src.Interval <= 0 {
+				return nil, fmt.Errorf("%s:%d: invalid interval %s", path, lineno, fields[1])
+			}
+		}
+		sources = append(sources, src)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read %s: %v", path, err)
+	}
+	return sources, nil
+}
+
+// feedSources returns the feeds given as arguments, followed by the feeds in
+// the FeedsFile if there is one.
+func (r *Runner) feedSources(args []string) ([]feedSource, error) {
+	sources, err := parseFeedArgs(args, r.DestDir)
+	if err != nil || r.FeedsFile == "" {
+		return sources, err
+	}
+	listed, err := readFeedsFile(r.FeedsFile, r.DestDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, listed...), nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// guidDB records which episodes have been downloaded, so that they aren't
+// downloaded again after being deleted. It's stored as a text file with one
+// line per episode, giving the feed key and episode key separated by a tab.
+// All the methods do nothing if called on a nil guidDB, which is what's used
+// if the database isn't enabled.
+type guidDB struct {
+	mu   sync.Mutex
+	path string
+	seen map[string]bool
+}
+
+// openGUIDDB loads the database from the given file, if it exists.
+func openGUIDDB(path string) (*guidDB, error) {
+	db := &guidDB{path: path, seen: make(map[string]bool)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			db.seen[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// episodeKey returns the key used to identify an episode in the database: its
+// GUID, or its enclosure URL if it doesn't have one.
+func episodeKey(item *podcast.Item, enc *podcast.Enclosure) string {
+	if guid := itemGUID(item); guid != "" {
+		return guid
+	}
+	return enc.URL
+}
+
+// feedKey returns the key used to identify a feed in the database: its
+// podcast:guid, which stays the same when the feed moves to a new host, or its
+// title if it doesn't have one.
+func feedKey(channel *podcast.Channel) string {
+	if guid := strings.TrimSpace(channel.PodcastGUID); guid != "" {
+		return guid
+	}
+	return channel.Title
+}
+
+func dbLine(feed string, key string) string {
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	return clean.Replace(feed) + "\t" + clean.Replace(key)
+}
+
+// has reports whether the episode of the feed has been downloaded.
+func (db *guidDB) has(feed string, key string) bool {
+	if db == nil {
+		return false
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.seen[dbLine(feed, key)]
+}
+
+// add records that the episode of the feed has been downloaded.
+func (db *guidDB) add(feed string, key string) {
+	if db == nil {
+		return
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	line := dbLine(feed, key)
+	if db.seen[line] {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(db.path), 0777); err != nil {
+		logError("can't create directory for GUID database %s: %v", db.path, err)
+		return
+	}
+	f, err := os.OpenFile(db.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		logError("can't open GUID database %s: %v", db.path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		logError("can't write GUID database %s: %v", db.path, err)
+		return
+	}
+	db.seen[line] = true
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// How long a download may go without receiving any data before it's abandoned
+const downloadReadTimeout = 2 * time.Minute
+
+// feedClient fetches feeds, which are small, so it gives up quickly.
+var feedClient = &http.Client{
+	Timeout:       30 * time.Second,
+	CheckRedirect: sameHostAuth,
+}
+
+// downloadClient fetches episodes, which can be large and slow. Rather than a
+// short overall timeout it relies on downloadReadTimeout to notice stalled
+// downloads. Compression is disabled as audio and video don't compress, and
+// transparent decompression stops io.Copy from streaming directly to disk.
+var downloadClient = &http.Client{
+	Timeout:       6 * time.Hour,
+	CheckRedirect: sameHostAuth,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		DisableCompression:    true,
+	},
+}
+
+// newRequest returns a request for a URL found in a feed, such as an
+// enclosure. If the URL doesn't contain credentials but ~/.netrc has an entry
+// for that particular host, they're sent using Basic Auth. The default entry
+// isn't used, as the host is chosen by the feed and is often a CDN or other
+// third party.
+func newRequest(ctx context.Context, method string, rawurl string) (*http.Request, error) {
+	return newAuthRequest(ctx, method, rawurl, false)
+}
+
+// newFeedRequest returns a request for a URL given by the user, such as a
+// feed. It's like newRequest, except that the default entry in ~/.netrc is
+// used if there's no entry for the host.
+func newFeedRequest(ctx context.Context, method string, rawurl string) (*http.Request, error) {
+	return newAuthRequest(ctx, method, rawurl, true)
+}
+
+// sameHostAuth is the CheckRedirect function for both clients. net/http only
+// drops the Authorization header when a redirect leaves the domain; this also
+// drops it for other hosts in the domain, such as a CDN subdomain, so that
+// credentials from ~/.netrc are only sent to the host they were found for.
+func sameHostAuth(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+func newAuthRequest(ctx context.Context, method string, rawurl string, useDefault bool) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.User == nil {
+		if login, password, ok := netrcLookup(req.URL.Hostname(), useDefault); ok {
+			req.SetBasicAuth(login, password)
+		}
+	}
+	return req, nil
+}
+
+// idleTimeoutReader resets a timer each time data is read, so that the timer
+// only fires if the reader stalls.
+type idleTimeoutReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (itr *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := itr.r.Read(p)
+	itr.timer.Reset(itr.timeout)
+	return n, err
+}
+
+// Response headers which some servers and CDNs use to give the length of
+// audio or video files
+var durationHeaders = []string{"X-Content-Duration", "X-Duration", "Content-Duration"}
+
+// probeDuration makes a HEAD request for the enclosure URL, and returns the
+// duration given in the response headers, or zero if there isn't one.
+func probeDuration(rawurl string) time.Duration {
+	req, err := newRequest(context.Background(), http.MethodHead, rawurl)
+	if err != nil {
+		return 0
+	}
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		logDebug("can't probe duration of %s: %v", rawurl, err)
+		return 0
+	}
+	resp.Body.Close()
+	for _, h := range durationHeaders {
+		val := strings.TrimSpace(resp.Header.Get(h))
+		if val == "" {
+			continue
+		}
+		if secs, err := strconv.ParseFloat(val, 64); err == nil {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if d, err := podcast.ParseDuration(val); err == nil {
+			return d
+		}
+	}
+	return 0
+}
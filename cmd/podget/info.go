@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Max length of the description printed by -info, in characters
+const infoDescriptionLength = 200
+
+// showInfo prints a summary of each feed to stdout, without downloading
+// anything.
+func (r *Runner) showInfo(feedurls []string) {
+	for i, feedurl := range feedurls {
+		channel, err := r.fetchAll(feedurl)
+		if err != nil {
+			logError("can't process %s: %v", feedurl, err)
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printInfo(feedurl, channel)
+	}
+}
+
+func printInfo(feedurl string, channel *podcast.Channel) {
+	var total podcast.Duration
+	for _, item := range channel.Item {
+		total += item.Duration
+	}
+	description := []rune(channel.PlainDescription())
+	if len(description) > infoDescriptionLength {
+		description = append(description[:infoDescriptionLength], '…')
+	}
+	explicit := channel.Explicit
+	if explicit == "" {
+		explicit = "unknown"
+	}
+	fmt.Printf("Feed:        %s\n", feedurl)
+	fmt.Printf("Title:       %s\n", channel.Title)
+	fmt.Printf("Author:      %s\n", channel.Author)
+	fmt.Printf("Description: %s\n", string(description))
+	fmt.Printf("Language:    %s\n", channel.Language)
+	fmt.Printf("Explicit:    %s\n", explicit)
+	fmt.Printf("Episodes:    %d\n", len(channel.Item))
+	fmt.Printf("Duration:    %s\n", total.String())
+	fmt.Printf("Size:        %.1f MiB\n", float64(channel.EstimatedStorageBytes())/(1024*1024))
+	fmt.Printf("Newest:      %s\n", itemDate(channel.LatestItem()))
+	fmt.Printf("Oldest:      %s\n", itemDate(channel.OldestItem()))
+	if channel.TTL > 0 {
+		fmt.Printf("TTL:         %d minutes\n", channel.TTL)
+	}
+	if len(channel.Funding) > 0 {
+		fmt.Printf("Funding:     %s\n", fundingText(channel.Funding[0]))
+	}
+}
+
+func fundingText(f *podcast.Funding) string {
+	if f.Message == "" {
+		return f.URL
+	}
+	return fmt.Sprintf("%s (%s)", f.URL, f.Message)
+}
+
+func itemDate(item *podcast.Item) string {
+	if item == nil {
+		return "unknown"
+	}
+	return item.PubDate.Format("2006-01-02")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var latestMu sync.Mutex
+
+// Publication dates of the episodes latest.<ext> currently points to, by
+// feed directory
+var latestDates = make(map[string]time.Time)
+
+// updateLatest makes latest.<ext> in the download's directory point to the
+// downloaded episode, unless a newer episode has already been downloaded in
+// this run.
+func updateLatest(dl *Download) {
+	latestMu.Lock()
+	defer latestMu.Unlock()
+	dir := filepath.Dir(dl.File)
+	if newest, ok := latestDates[dir]; ok && dl.Item.PubDate.Before(newest) {
+		return
+	}
+	latestDates[dir] = dl.Item.PubDate.Time
+	link := filepath.Join(dir, "latest"+filepath.Ext(dl.File))
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		logError("can't remove %s: %v", link, err)
+		return
+	}
+	if err := linkLatest(dl.File, link); err != nil {
+		logError("can't create %s: %v", link, err)
+		return
+	}
+	logDebug("%s now points to %s", link, dl.File)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// linkLatest creates a symlink at link pointing to file, which is in the same
+// directory.
+func linkLatest(file string, link string) error {
+	return os.Symlink(filepath.Base(file), link)
+}
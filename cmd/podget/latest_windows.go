@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// linkLatest copies file to link, as symlinks need special privileges on
+// Windows.
+func linkLatest(file string, link string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(link)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
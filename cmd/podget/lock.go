@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the lock file created in the destination directory
+const lockFileName = ".podget.lock"
+
+// errLocked is returned by lockFile if another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// lockDestDir locks a download directory, so that two copies of podget can't
+// download to it at the same time. The lock lasts until the returned file is
+// closed or the process exits.
+func lockDestDir(dir string) (*os.File, error) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("can't create destination directory %s: %v", dir, err)
+	}
+	path := filepath.Join(dir, lockFileName)
+	f, err := lockFile(path)
+	if errors.Is(err, errLocked) {
+		return nil, fmt.Errorf("another podget is already downloading to %s", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't lock %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// dirLocks holds the locks on the directories podget downloads to: the
+// destination directory, and the directories of any feeds given as URL@dir
+// which are outside it.
+type dirLocks struct {
+	destdir string
+	held    map[string]*os.File
+}
+
+func newDirLocks(destdir string) *dirLocks {
+	return &dirLocks{destdir: destdir, held: make(map[string]*os.File)}
+}
+
+// lockSources locks the destination directory and the directories of the
+// feeds which are outside it, skipping any which are already locked.
+func (dl *dirLocks) lockSources(sources []feedSource) error {
+	if err := dl.lock(dl.destdir); err != nil {
+		return err
+	}
+	for _, src := range sources {
+		if !isWithin(dl.destdir, src.Dir) {
+			if err := dl.lock(src.Dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (dl *dirLocks) lock(dir string) error {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("can't lock %s: %v", dir, err)
+	}
+	if _, ok := dl.held[key]; ok {
+		return nil
+	}
+	f, err := lockDestDir(dir)
+	if err != nil {
+		return err
+	}
+	dl.held[key] = f
+	return nil
+}
+
+// Close releases all the locks.
+func (dl *dirLocks) Close() {
+	for key, f := range dl.held {
+		f.Close()
+		delete(dl.held, key)
+	}
+}
+
+// isWithin reports whether dir is parent or one of its subdirectories.
+func isWithin(parent string, dir string) bool {
+	ap, err := filepath.Abs(parent)
+	if err != nil {
+		return false
+	}
+	ad, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(ap, ad)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// netrcEntry holds the credentials for one machine in a .netrc file.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+var netrcOnce sync.Once
+var netrcEntries []netrcEntry
+
+// netrcPath returns the location of the .netrc file, which can be overridden
+// with the NETRC environment variable as with curl.
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc parses the contents of a .netrc file. The default entry, if any,
+// is returned with an empty machine name. Macro definitions are skipped.
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var cur *netrcEntry
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			next := ""
+			if j+1 < len(fields) {
+				next = fields[j+1]
+			}
+			switch fields[j] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: next})
+				cur = &entries[len(entries)-1]
+				j++
+			case "default":
+				entries = append(entries, netrcEntry{})
+				cur = &entries[len(entries)-1]
+			case "login":
+				if cur != nil {
+					cur.login = next
+				}
+				j++
+			case "password":
+				if cur != nil {
+					cur.password = next
+				}
+				j++
+			case "account":
+				j++
+			case "macdef":
+				// The macro body runs until the next blank line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	return entries
+}
+
+// netrcLookup returns the login and password from ~/.netrc for the host,
+// falling back to the default entry if useDefault is set. The file is read
+// the first time it's needed.
+func netrcLookup(host string, useDefault bool) (string, string, bool) {
+	netrcOnce.Do(func() {
+		p := netrcPath()
+		if p == "" {
+			return
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logError("can't read %s: %v", p, err)
+			}
+			return
+		}
+		netrcEntries = parseNetrc(string(data))
+	})
+	var def *netrcEntry
+	for i, e := range netrcEntries {
+		if e.machine == "" && def == nil {
+			def = &netrcEntries[i]
+		}
+		if strings.EqualFold(e.machine, host) {
+			return e.login, e.password, true
+		}
+	}
+	if def != nil && useDefault {
+		return def.login, def.password, true
+	}
+	return "", "", false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+type opmlDoc struct {
+	XMLName xml.Name       `xml:"opml"`
+	Version string         `xml:"version,attr"`
+	Title   string         `xml:"head>title"`
+	Created string         `xml:"head>dateCreated"`
+	Body    []*opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+// fetchedFeed is a feed URL along with the channel fetched from it, which is
+// nil if the feed couldn't be fetched.
+type fetchedFeed struct {
+	URL     string
+	Channel *podcast.Channel
+}
+
+// writeOPML writes an OPML 2.0 subscription list for the feeds. Feeds are
+// put in a folder named after their first iTunes category, if they have one.
+func writeOPML(w io.Writer, feeds []fetchedFeed) error {
+	doc := opmlDoc{
+		Version: "2.0",
+		Title:   "podget subscriptions",
+		Created: time.Now().Format(time.RFC1123Z),
+	}
+	folders := make(map[string]*opmlOutline)
+	for _, feed := range feeds {
+		ol := &opmlOutline{Text: feed.URL, Type: "rss", XMLURL: feed.URL}
+		category := ""
+		if ch := feed.Channel; ch != nil {
+			ol.Text = ch.Title
+			ol.Title = ch.Title
+			ol.HTMLURL = ch.Link
+			for _, cat := range ch.Category {
+				if cat.AttrText != "" {
+					category = cat.AttrText
+					break
+				}
+			}
+		}
+		if category == "" {
+			doc.Body = append(doc.Body, ol)
+			continue
+		}
+		folder, ok := folders[category]
+		if !ok {
+			folder = &opmlOutline{Text: category}
+			folders[category] = folder
+			doc.Body = append(doc.Body, folder)
+		}
+		folder.Outlines = append(folder.Outlines, ol)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// exportOPMLFile writes the OPML subscription list to the -export-opml file.
+func exportOPMLFile(path string, feeds []fetchedFeed) {
+	fout, err := os.Create(path)
+	if err != nil {
+		logError("can't create %s: %v", path, err)
+		return
+	}
+	err = writeOPML(fout, feeds)
+	if cerr := fout.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logError("can't write %s: %v", path, err)
+		return
+	}
+	logInfo("wrote %d feeds to %s", len(feeds), path)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Values accepted by -output-format
+var outputFormats = []string{"csv", "json", "opml"}
+
+// episodeRecord is an episode as listed by -output-format csv or json.
+type episodeRecord struct {
+	FeedTitle      string  `json:"feed_title"`
+	EpisodeTitle   string  `json:"episode_title"`
+	PubDate        string  `json:"pub_date"`
+	Duration       float64 `json:"duration"` // Seconds
+	EnclosureURL   string  `json:"enclosure_url"`
+	EnclosureBytes int64   `json:"enclosure_bytes"`
+	GUID           string  `json:"guid"`
+	Link           string  `json:"link"` // The episode's web page
+}
+
+var episodeCSVHeader = []string{
+	"feed_title", "episode_title", "pub_date", "duration",
+	"enclosure_url", "enclosure_bytes", "guid", "link",
+}
+
+func newEpisodeRecord(channel *podcast.Channel, item *podcast.Item) episodeRecord {
+	rec := episodeRecord{
+		FeedTitle:    channel.Title,
+		EpisodeTitle: item.Title,
+		Duration:     time.Duration(item.Duration).Seconds(),
+		GUID:         itemGUID(item),
+		Link:         item.Link,
+	}
+	if !item.PubDate.IsZero() {
+		rec.PubDate = item.PubDate.Format(time.RFC3339)
+	}
+	enc := item.Enclosure
+	if enc == nil {
+		enc = item.MediaEnclosure()
+	}
+	if enc != nil {
+		rec.EnclosureURL = enc.URL
+		rec.EnclosureBytes = enc.Length
+	}
+	return rec
+}
+
+func (rec episodeRecord) csvRow() []string {
+	return []string{
+		rec.FeedTitle, rec.EpisodeTitle, rec.PubDate,
+		strconv.FormatFloat(rec.Duration, 'f', -1, 64),
+		rec.EnclosureURL, strconv.FormatInt(rec.EnclosureBytes, 10), rec.GUID,
+		rec.Link,
+	}
+}
+
+// writeOutput fetches the feeds and writes a list of them to stdout in the
+// given format, without downloading anything. The csv and json formats list
+// every episode; opml lists the feeds.
+func (r *Runner) writeOutput(feedurls []string, format string) error {
+	var feeds []fetchedFeed
+	for _, feedurl := range feedurls {
+		channel, err := r.fetchAll(feedurl)
+		if err != nil {
+			logError("can't process %s: %v", feedurl, err)
+		}
+		feeds = append(feeds, fetchedFeed{URL: feedurl, Channel: channel})
+	}
+	if format == "opml" {
+		return writeOPML(os.Stdout, feeds)
+	}
+	records := []episodeRecord{}
+	for _, feed := range feeds {
+		if feed.Channel == nil {
+			continue
+		}
+		for _, item := range feed.Channel.Item {
+			records = append(records, newEpisodeRecord(feed.Channel, item))
+		}
+	}
+	switch format {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(episodeCSVHeader)
+		for _, rec := range records {
+			w.Write(rec.csvRow())
+		}
+		w.Flush()
+		return w.Error()
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+	return fmt.Errorf("unknown output format %s", format)
+}
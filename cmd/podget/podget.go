@@ -12,27 +12,39 @@
 package main
 
 import (
-	"encoding/xml"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
-	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/lpar/podtools/podcast"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
 )
 
 // Max number of downloads to queue
 const queueSize = 15
 
+// How long to wait before the first retry of a failed download; subsequent
+// retries wait longer
+const retryDelay = 10 * time.Second
+
 func logInfo(msg string, vals ...interface{}) {
 	if *verbose {
 		fmt.Printf(msg+"\n", vals...)
@@ -49,95 +61,458 @@ func logError(msg string, vals ...interface{}) {
 	fmt.Fprintf(os.Stderr, msg+"\n", vals...)
 }
 
-type Download struct {
-	URL  string
-	File string
+func logWarn(msg string, vals ...interface{}) {
+	fmt.Fprintf(os.Stderr, "warning: "+msg+"\n", vals...)
 }
 
-var dlqueue = make(chan *Download, queueSize)
+// downloader downloads a file from the queue, retrying if necessary.
+func (r *Runner) downloader(dl *Download) {
+	n, err := r.download(dl.URL, dl.File, dl.Integrity)
+	for attempt := 1; isRetryable(err) && attempt <= r.Retries; attempt++ {
+		logError("%v", err)
+		logWarn("retrying %s, attempt %d of %d", dl.URL, attempt, r.Retries)
+		time.Sleep(time.Duration(attempt) * retryDelay)
+		n, err = r.download(dl.URL, dl.File, dl.Integrity)
+	}
+	if err != nil {
+		logError("%v", err)
+	} else {
+		r.downloaded(dl)
+	}
+	dl.Report.finished(n, err)
+}
 
-func downloader() {
-	logDebug("download task starting")
-	for dl := range dlqueue {
-		download(dl.URL, dl.File)
-		time.Sleep(2 * time.Second)
+// downloaded is called after each successful download.
+func (r *Runner) downloaded(dl *Download) {
+	if dl.Item != nil {
+		r.guids.add(dl.Feed, dl.Key)
+	}
+	if !r.perFeedDirs() {
+		return
 	}
-	logDebug("all downloads complete, download task finishing")
+	if r.SymlinkLatest && dl.Item != nil {
+		updateLatest(dl)
+	}
+	if r.KeepN > 0 {
+		r.enforceKeep(filepath.Dir(dl.File))
+	}
+	if r.Quota > 0 {
+		r.enforceQuota(filepath.Dir(dl.File))
+	}
+}
+
+// retryableError is a download error which might not happen if the download
+// is retried, such as a network error or an HTTP 5xx response.
+type retryableError struct {
+	err error
+}
+
+func (re retryableError) Error() string {
+	return re.err.Error()
 }
 
-func download(fromurl string, tofile string) {
+func (re retryableError) Unwrap() error {
+	return re.err
+}
+
+func isRetryable(err error) bool {
+	var re retryableError
+	return errors.As(err, &re)
+}
+
+// download fetches fromurl and writes it to tofile, returning the number of
+// bytes written. If integrity is non-nil, the file is only kept if its hash
+// matches. Errors which might not happen if the download is retried are
+// wrapped in retryableError.
+func (r *Runner) download(fromurl string, tofile string, integrity *podcast.Integrity) (int64, error) {
 	logDebug("beginning download %s -> %s", fromurl, tofile)
 	dir := path.Dir(tofile)
 	err := os.MkdirAll(dir, 0777)
 	if err != nil {
-		logError("can't create destination directory %s: %v", dir)
-		return
+		return 0, fmt.Errorf("can't create destination directory %s: %v", dir, err)
 	}
-	fout, err := os.Create(tofile)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := newRequest(ctx, http.MethodGet, fromurl)
 	if err != nil {
-		logError("can't create %s: %v", tofile, err)
-		return
+		return 0, fmt.Errorf("can't download %s: %v", fromurl, err)
 	}
-	defer fout.Close()
-	resp, err := http.Get(fromurl)
+	resp, err := downloadClient.Do(req)
 	if err != nil {
-		logError("can't download %s: %v", fromurl, err)
-		return
+		return 0, retryableError{fmt.Errorf("can't download %s: %v", fromurl, err)}
 	}
 	defer resp.Body.Close()
-	n, err := io.Copy(fout, resp.Body)
+	if resp.StatusCode >= 400 {
+		err := fmt.Errorf("can't download %s: server returned HTTP %d", fromurl, resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			return 0, retryableError{err}
+		}
+		return 0, err
+	}
+	// Download to a temporary file and rename it into place, so that an
+	// interrupted download never leaves a partial file that looks complete.
+	fout, err := os.CreateTemp(dir, ".podget-*")
 	if err != nil {
-		logError("error downloading %s: %v", fromurl, err)
-		return
+		return 0, fmt.Errorf("can't create temporary file in %s: %v", dir, err)
+	}
+	defer os.Remove(fout.Name())
+	timer := time.AfterFunc(downloadReadTimeout, cancel)
+	defer timer.Stop()
+	var body io.Reader = &idleTimeoutReader{r: resp.Body, timer: timer, timeout: downloadReadTimeout}
+	if r.bandwidth != nil {
+		body = &rateLimitedReader{r: body, rl: r.bandwidth}
+	}
+	var out io.Writer = fout
+	var sum hash.Hash
+	if integrity != nil {
+		if sum, err = integrity.NewHash(); err != nil {
+			logWarn("can't check %s: %v", fromurl, err)
+		} else {
+			out = io.MultiWriter(fout, sum)
+		}
+	}
+	n, err := io.Copy(out, body)
+	if err != nil {
+		fout.Close()
+		return 0, retryableError{fmt.Errorf("error downloading %s: %v", fromurl, err)}
+	}
+	if sum != nil && !integrity.Matches(sum.Sum(nil)) {
+		fout.Close()
+		return 0, retryableError{fmt.Errorf("error downloading %s: %s hash doesn't match podcast:integrity", fromurl, integrity.Type)}
+	}
+	if err := fout.Close(); err != nil {
+		return 0, fmt.Errorf("can't write %s: %v", fout.Name(), err)
+	}
+	// CreateTemp makes files only readable by the owner
+	if err := os.Chmod(fout.Name(), 0644); err != nil {
+		logError("can't set permissions on %s: %v", fout.Name(), err)
+	}
+	if err := os.Rename(fout.Name(), tofile); err != nil {
+		return 0, fmt.Errorf("can't create %s: %v", tofile, err)
 	}
 	logInfo("%d bytes downloaded to %s", n, tofile)
 	logDebug("ending download %s -> %s", fromurl, tofile)
+	return n, nil
 }
 
 var asciiOnly = regexp.MustCompile("[[:^ascii:]]")
 
-func processChannel(rss []byte) error {
-	logDebug("processing channel data [%s]", string(rss[0:40]))
-	var feed podcast.RSS
-	err := xml.Unmarshal(rss, &feed)
+// isXMLChar reports whether r is in the set of characters allowed by the
+// XML 1.0 spec.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+// limitedReader reads from r until more than n bytes have been read, then
+// returns errFeedTooLarge.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+var errFeedTooLarge = errors.New("feed is too large")
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > lr.n+1 {
+		p = p[:lr.n+1]
+	}
+	n, err := lr.r.Read(p)
+	if int64(n) > lr.n {
+		n = int(lr.n)
+		err = errFeedTooLarge
+	}
+	lr.n -= int64(n)
+	return n, err
+}
+
+// parseChannel parses an RSS, Atom or JSON feed, returning its channel. The
+// feed is streamed to the parser for its format, rather than being read into
+// memory first. With -lenient, a copy of what the parser reads is kept, so
+// that if the feed won't parse it can be parsed again with any characters
+// which aren't allowed in XML removed.
+func (r *Runner) parseChannel(in io.Reader) (*podcast.Channel, error) {
+	br := bufio.NewReader(in)
+	head, _ := br.Peek(40)
+	logDebug("processing channel data [%s]", string(head))
+	var src io.Reader = br
+	var seen bytes.Buffer
+	if r.Lenient {
+		src = io.TeeReader(br, &seen)
+	}
+	feed, err := podcast.DefaultParser.Parse(src)
+	if err != nil && r.Lenient && !errors.Is(err, errFeedTooLarge) {
+		// Strip any characters which aren't legal in XML 1.0, such as control
+		// characters and null bytes. Invalid UTF-8 is replaced by U+FFFD.
+		stripped := transform.NewReader(io.MultiReader(&seen, br), runes.Remove(runes.Predicate(func(r rune) bool {
+			return !isXMLChar(r)
+		})))
+		if lenientFeed, lenientErr := podcast.DefaultParser.Parse(stripped); lenientErr == nil {
+			logWarn("removed characters which aren't allowed in XML from feed, as it wouldn't parse: %v", err)
+			feed, err = lenientFeed, nil
+		}
+	}
 	if err != nil {
-		return fmt.Errorf("error parsing XML: %v", err)
+		return nil, fmt.Errorf("error parsing feed: %w", err)
+	}
+	if feed.Channel == nil {
+		return nil, fmt.Errorf("no channel element found")
 	}
-	channel := feed.Channel
-	name := asciiOnly.ReplaceAllLiteralString(channel.Title, "")
-	dir := strings.Replace(name, " ", "_", -1)
+	return feed.Channel, nil
+}
+
+// processChannel queues downloads for the items in the channel, to the given
+// download directory.
+func (r *Runner) processChannel(channel *podcast.Channel, basedir string, report *feedReport) {
+	dir := r.feedDir(channel)
 	logInfo("%s %s/", channel.Title, dir)
+	if channel.TTL > 0 {
+		logInfo("  feed should be cached for %d minutes", channel.TTL)
+	}
+	if r.FilterExplicit && !r.AllowExplicit && isExplicit(channel.Explicit) {
+		logInfo("skipping %s, feed is marked explicit", channel.Title)
+		for range channel.Item {
+			report.skipped()
+		}
+		return
+	}
+	var prefixes map[*podcast.Item]string
+	if r.NumberEpisodes {
+		prefixes = episodeNumbers(channel.Item)
+	}
+	var eligible []*podcast.Item
+	encs := make(map[*podcast.Item]*podcast.Enclosure)
 	for _, item := range channel.Item {
 		logDebug("processing item")
-		processItem(channel.Title, dir, item)
+		if enc := r.eligibleEnclosure(item); enc != nil {
+			eligible = append(eligible, item)
+			encs[item] = enc
+		} else {
+			report.skipped()
+		}
+	}
+	if r.EpisodeRange != nil {
+		eligible = orderEpisodes(eligible, r.EpisodeOrder)
+		inRange := r.EpisodeRange.apply(eligible)
+		for i := len(inRange); i < len(eligible); i++ {
+			report.skipped()
+		}
+		logDebug("%d of %d episodes are in -episode-range", len(inRange), len(eligible))
+		eligible = inRange
+	}
+	for _, item := range eligible {
+		if !r.processItem(channel, basedir, dir, prefixes[item], item, encs[item], report) {
+			report.skipped()
+		}
 	}
 	logDebug("done processing channel data")
-	return nil
 }
 
-func processItem(feedtitle string, feeddir string, item *podcast.Item) {
+// episodeNumbers numbers the items in order of publication, oldest first,
+// returning a map from each item to its number as a filename prefix such as
+// "001_". Numbers are padded to at least three digits.
+func episodeNumbers(items []*podcast.Item) map[*podcast.Item]string {
+	sorted := make([]*podcast.Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].PubDate.Before(sorted[j].PubDate.Time)
+	})
+	width := len(strconv.Itoa(len(sorted)))
+	if width < 3 {
+		width = 3
+	}
+	prefixes := make(map[*podcast.Item]string, len(sorted))
+	for i, item := range sorted {
+		prefixes[item] = fmt.Sprintf("%0*d_", width, i+1)
+	}
+	return prefixes
+}
+
+// eligibleEnclosure returns the enclosure to download for the item, or nil if
+// it doesn't have one or it's excluded by the filtering options.
+func (r *Runner) eligibleEnclosure(item *podcast.Item) *podcast.Enclosure {
 	enc := item.Enclosure
-	logInfo("  %v %s %v", item.PubDate.Format("2006-01-02"), item.Title, item.Duration.String())
-	u, err := url.Parse(enc.URL)
+	if r.PreferType != "" {
+		if alt := item.EnclosureOfType(r.PreferType); alt != nil {
+			enc = alt
+		}
+	}
+	if enc == nil {
+		enc = item.MediaEnclosure()
+	}
+	if enc == nil {
+		logDebug("skipping %s, no enclosure", item.Title)
+		return nil
+	}
+	if r.FilterExplicit && isExplicit(item.Explicit) {
+		logInfo("skipping %s, episode is marked explicit", item.Title)
+		return nil
+	}
+	if !r.sizeAllowed(enc.Length) {
+		logInfo("skipping %s, size %d bytes is outside the allowed range", item.Title, enc.Length)
+		return nil
+	}
+	if !r.mimeAllowed(enc.MIMEType) {
+		logDebug("skipping %s, type %s doesn't match -mime-filter", item.Title, enc.MIMEType)
+		return nil
+	}
+	if r.MaxFutureDays >= 0 {
+		limit := time.Now().Add(time.Duration(r.MaxFutureDays) * 24 * time.Hour)
+		if item.PubDate.After(limit) {
+			logWarn("skipping %s, publication date %s is in the future", item.Title, item.PubDate.Format("2006-01-02"))
+			return nil
+		}
+	}
+	// Probing for a missing duration takes an HTTP request, so it's left to
+	// processItem, once it knows the episode hasn't already been downloaded
+	if item.Duration == 0 && r.ProbeDurations {
+		return enc
+	}
+	if !r.durationAllowed(time.Duration(item.Duration)) {
+		logInfo("skipping %s, duration %s is outside the allowed range", item.Title, item.Duration.String())
+		return nil
+	}
+	return enc
+}
+
+// processItem queues the item's enclosure for download, to a file whose name
+// starts with prefix, in the place within basedir given by feeddir and
+// -dir-strategy. It returns false if the episode was skipped.
+func (r *Runner) processItem(channel *podcast.Channel, basedir string, feeddir string, prefix string, item *podcast.Item, enc *podcast.Enclosure, report *feedReport) bool {
+	duration := "unknown"
+	if item.Duration != 0 {
+		duration = item.Duration.String()
+	}
+	logInfo("  %v %s %v guid=%s", item.PubDate.Format("2006-01-02"), item.Title, duration, itemGUID(item))
+	filename, err := enc.Filename()
 	if err != nil {
-		logError("can't parse URL %s for %s: %v", enc.URL, feedtitle, err)
-		return
+		logError("can't get filename for %s: %v", channel.Title, err)
+		return false
 	}
-	var destfile string
-	if *podtrac != "" {
-		destfile, err = depodtracify(item, enc, u, filepath.Ext(u.Path))
+	if r.Podtrac != nil {
+		filename, err = r.depodtracify(item, enc)
 		if err != nil {
 			logError("skipping episode: %v", err)
-			return
+			return false
 		}
-		destfile = filepath.Join(*destdir, feeddir, destfile)
+	}
+	if name, ok, err := r.episodeFilename(item, enc.Extension()); err != nil {
+		logError("can't format filename for %s: %v", item.Title, err)
+		return false
+	} else if ok {
+		filename = name
+	}
+	destfile := r.episodePath(basedir, feeddir, item, prefix+filename)
+	recordPubDate(destfile, item.PubDate.Time)
+	feed := feedKey(channel)
+	key := episodeKey(item, enc)
+	if r.MarkAllDownloaded {
+		logInfo("marking %s as downloaded, guid=%s", destfile, itemGUID(item))
+		r.guids.add(feed, key)
+		return false
+	}
+	// Episodes recorded before the feed had a podcast:guid are under its title
+	if _, err := os.Stat(destfile); os.IsNotExist(err) && (r.guids.has(feed, key) || r.guids.has(channel.Title, key)) {
+		logInfo("skipping %s, already in GUID database, guid=%s", destfile, itemGUID(item))
+		return false
+	}
+	queued := r.needsDownload(destfile, enc.Length)
+	if queued && item.Duration == 0 && r.ProbeDurations {
+		item.Duration = podcast.Duration(probeDuration(enc.URL))
+		if !r.durationAllowed(time.Duration(item.Duration)) {
+			logInfo("skipping %s, duration %s is outside the allowed range", item.Title, item.Duration.String())
+			return false
+		}
+	}
+	if queued {
+		if r.Validate {
+			validateEnclosure(item, enc)
+		}
+		report.queued()
+		r.downloads.QueueDownload(&Download{URL: enc.URL, File: destfile, Item: item, Feed: feed, Key: key, Report: report, Integrity: enc.Integrity})
 	} else {
-		destfile = filepath.Join(*destdir, feeddir, filepath.Base(u.Path))
+		logError("skipping %s, already downloaded, guid=%s", destfile, itemGUID(item))
+	}
+	if r.Transcripts {
+		r.queueTranscripts(item, destfile)
+	}
+	if r.Chapters {
+		r.queueChapters(item, destfile)
+	}
+	return queued
+}
+
+// itemGUID returns the item's GUID, or an empty string if it doesn't have one.
+func itemGUID(item *podcast.Item) string {
+	if item.Guid == nil {
+		return ""
 	}
+	return strings.TrimSpace(item.Guid.Text)
+}
+
+// isExplicit reports whether an itunes:explicit value marks content as
+// explicit. Older feeds use yes, newer ones true.
+func isExplicit(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "explicit":
+		return true
+	}
+	return false
+}
+
+// durationAllowed reports whether an episode of the given length should be
+// downloaded according to -min-duration, -max-duration and
+// -zero-duration-policy. A zero duration means the feed didn't say.
+func (r *Runner) durationAllowed(d time.Duration) bool {
+	if d == 0 {
+		return !r.SkipZeroDuration
+	}
+	if r.MinDuration > 0 && d < r.MinDuration {
+		return false
+	}
+	if r.MaxDuration > 0 && d > r.MaxDuration {
+		return false
+	}
+	return true
+}
+
+// sizeAllowed reports whether an enclosure of the given length should be
+// downloaded according to -min-size and -max-size. A zero length means the
+// feed didn't say, so it's always allowed.
+func (r *Runner) sizeAllowed(length int64) bool {
+	if length == 0 {
+		return true
+	}
+	return length >= r.MinSize && (r.MaxSize == 0 || length <= r.MaxSize)
+}
+
+// mimeAllowed reports whether the MIME type starts with one of the
+// comma-separated prefixes given by -mime-filter.
+func (r *Runner) mimeAllowed(mimetype string) bool {
+	if r.MIMEFilter == "" {
+		return true
+	}
+	mimetype = strings.ToLower(mimetype)
+	for _, prefix := range strings.Split(r.MIMEFilter, ",") {
+		prefix = strings.ToLower(strings.TrimSpace(prefix))
+		if prefix != "" && strings.HasPrefix(mimetype, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// needsDownload reports whether destfile should be downloaded, either because
+// it doesn't exist, or because rerun processing is enabled and it's old, or
+// because -overwrite-on-size-change is set and its size doesn't match length.
+// A length of zero means the expected size isn't known.
+func (r *Runner) needsDownload(destfile string, length int64) bool {
 	stats, err := os.Stat(destfile)
 	overwrite := false
-	if err == nil && *maxdays > 0 {
-		maxage := time.Duration(*maxdays) * time.Hour * 24
+	if err == nil && r.RerunDays > 0 {
+		maxage := time.Duration(r.RerunDays) * time.Hour * 24
 		age := time.Since(stats.ModTime()).Round(time.Second)
 		overwrite = age > maxage
 		fw := "not "
@@ -146,36 +521,76 @@ func processItem(feedtitle string, feeddir string, item *podcast.Item) {
 		}
 		logInfo("%sallowing overwrite of %s, file is %v old", fw, destfile, age)
 	}
-	if os.IsNotExist(err) || overwrite {
-		dlqueue <- &Download{URL: enc.URL, File: destfile}
-		return
+	if err == nil && !overwrite && r.OverwriteOnSizeChange && sizeChanged(stats.Size(), length) {
+		logInfo("allowing overwrite of %s, file is %d bytes but feed says %d", destfile, stats.Size(), length)
+		overwrite = true
 	}
-	logError("skipping %s, already downloaded", destfile)
+	return os.IsNotExist(err) || overwrite
+}
+
+// Fraction by which a file's size can differ from the enclosure length before
+// -overwrite-on-size-change thinks it has changed, to allow for servers which
+// rewrite metadata tags
+const sizeTolerance = 0.05
+
+// sizeChanged reports whether a file of the given size is too different from
+// the length given in the feed to be the same episode.
+func sizeChanged(size int64, length int64) bool {
+	if length <= 0 {
+		return false
+	}
+	diff := size - length
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) > float64(length)*sizeTolerance
+}
+
+// podtracFields lists the keys of the data depodtracify can search.
+var podtracFields = []string{
+	"item.author", "item.category", "item.description", "item.duration",
+	"item.guid", "item.pubDate", "item.title", "enclosure.url", "url",
+}
+
+// podtracRule is a compiled -podtrac instruction: a regexp whose first
+// submatch is the episode number, and the field it searches.
+type podtracRule struct {
+	field string
+	re    *regexp.Regexp
 }
 
 // depodtracify handles extracting an episode number from the data, in cases where the podcast
 // is using podtrac. Otherwise, every episode ends up with the same filename `default.mp3`.
-func depodtracify(item *podcast.Item, enc *podcast.Enclosure, u *url.URL, ext string) (string, error) {
+func (r *Runner) depodtracify(item *podcast.Item, enc *podcast.Enclosure) (string, error) {
+	u, err := url.Parse(enc.URL)
+	if err != nil {
+		return "", err
+	}
 	data := make(map[string]string)
 	data["item.author"] = item.Author
 	data["item.category"] = item.Category
 	data["item.description"] = item.Description
 	data["item.duration"] = item.Duration.String()
-	data["item.guid"] = item.Guid.Text
+	data["item.guid"] = itemGUID(item)
 	data["item.pubDate"] = item.PubDate.String()
 	data["item.title"] = item.Title
 	data["enclosure.url"] = enc.URL
 	data["url"] = u.String()
-	x := data[podtracField]
-	ep := podtracRE.FindStringSubmatch(x)
+	x := data[r.Podtrac.field]
+	ep := r.Podtrac.re.FindStringSubmatch(x)
 	if len(ep) < 1 || ep[1] == "" {
 		if *debug {
 			logDebug("search data: %s", x)
-			logDebug("     regexp: %s", podtracRE)
+			logDebug("     regexp: %s", r.Podtrac.re)
 		}
-		return "", fmt.Errorf("failed to extract filename for %s", u.String())
+		base := path.Base(u.Path)
+		if !r.PodtracFallback || base == "" || base == "." || base == "/" || base == "default.mp3" {
+			return "", fmt.Errorf("failed to extract filename for %s", u.String())
+		}
+		logWarn("failed to extract filename for %s, using %s", u.String(), base)
+		return base, nil
 	}
-	return ep[1] + ext, nil
+	return ep[1] + enc.Extension(), nil
 }
 
 var verbose = flag.Bool("v", false, "verbose output")
@@ -183,71 +598,367 @@ var debug = flag.Bool("debug", false, "debug mode")
 var destdir = flag.String("d", "", "destination directory")
 var maxdays = flag.Int("r", 0, "enable rerun processing after specified number of days")
 var podtrac = flag.String("podtrac", "", "how to extract episode number, see README")
+var podtracFallback = flag.Bool("podtrac-fallback", true, "use the URL filename if -podtrac can't extract an episode number")
+var interDownloadDelay = flag.Duration("inter-download-delay", 2*time.Second, "time to wait between downloads")
+var retries = flag.Int("retries", 2, "number of times to retry a download after a transient error")
+var maxFeedSize = flag.Int64("max-feed-size", 50*1024*1024, "maximum size of a feed in bytes")
+var lenient = flag.Bool("lenient", false, "if a feed won't parse, strip characters which aren't allowed in XML and try again")
+var followPages = flag.Bool("follow-pages", false, "follow RFC 5005 next page links to fetch older episodes")
+var daemon = flag.Bool("daemon", false, "keep running, fetching feeds repeatedly")
+var interval = flag.Duration("interval", 6*time.Hour, "time between feed fetches in daemon mode")
+var feedsFile = flag.String("feeds", "", "file listing feeds as URL[@dir] [interval], one per line; reloaded on SIGHUP in daemon mode")
+var transcripts = flag.Bool("transcripts", false, "download episode transcripts when available")
+var transcriptFormat = flag.String("transcript-format", "", "preferred transcript format, e.g. vtt or srt")
+var chapters = flag.Bool("chapters", false, "download episode chapter files when available")
+var maxFutureDays = flag.Int("max-future-days", 7, "skip episodes dated more than this many days in the future, negative to disable")
+var probeDurations = flag.Bool("probe-duration", false, "check HTTP headers for the duration of episodes which don't give one")
+var discoverMode = flag.Bool("discover", false, "treat arguments as website URLs and print their feed URLs")
+var exportOPML = flag.String("export-opml", "", "write an OPML list of the feeds to the given file")
+var slugMode = flag.String("slug-mode", "transliterate", "how to make directory names from feed titles: transliterate or ascii-only")
+var slugPlaceholder = flag.String("slug-placeholder", "", "replacement for characters which can't be transliterated, default is to keep them")
+var rateLimit = flag.String("rate-limit", "", "maximum total download speed per second, e.g. 500KB or 2MiB")
+var mimeFilter = flag.String("mime-filter", "", "only download enclosures whose MIME type starts with one of these comma-separated prefixes, e.g. audio/")
+var minDuration = flag.Duration("min-duration", 0, "skip episodes shorter than this")
+var maxDuration = flag.Duration("max-duration", 0, "skip episodes longer than this")
+var zeroDurationPolicy = flag.String("zero-duration-policy", "download", "what to do with episodes of unknown duration: skip or download")
+var minSize = flag.String("min-size", "", "skip enclosures smaller than this, e.g. 1MB")
+var maxSize = flag.String("max-size", "", "skip enclosures larger than this, e.g. 2GB")
+var numberEpisodes = flag.Bool("number-episodes", false, "prefix filenames with episode numbers in order of publication")
+var episodeTemplateFlag = flag.String("episode-template", "", "template for filenames of episodes with iTunes episode numbers, e.g. S{{.Season:02d}}E{{.Episode:02d}}_{{.Title | slug}}")
+var symlinkLatest = flag.Bool("symlink-latest", false, "link latest.<ext> in each feed directory to the newest episode downloaded")
+var quota = flag.String("quota", "", "delete the oldest files in a feed directory when it grows larger than this, e.g. 5GB")
+var quotaDryRun = flag.Bool("quota-dry-run", false, "print the files -quota would delete instead of deleting them")
+var keepN = flag.Int("keep-n", 0, "delete all but this many of the most recent episodes in each feed directory; implies -no-redownload-deleted")
+var keepGrace = flag.Duration("keep-grace", 24*time.Hour, "never let -keep-n delete files modified more recently than this")
+var reportFile = flag.String("report-file", "", "append a JSON summary of each feed's downloads to this file")
+var validate = flag.Bool("validate", false, "check enclosure URLs with a HEAD request and warn about problems")
+var filterExplicit = flag.Bool("filter-explicit", false, "skip episodes and feeds marked explicit")
+var allowExplicit = flag.Bool("allow-explicit", false, "with -filter-explicit, only skip episodes marked explicit, not whole feeds")
+var dirStrategy = flag.String("dir-strategy", "feed", "how to arrange downloads: "+strings.Join(dirStrategies, ", "))
+var conflictSuffix = flag.String("conflict-suffix", "_{feed}", "added to filenames used by more than one feed, with {feed} replaced by the feed's directory name")
+var overwriteOnSizeChange = flag.Bool("overwrite-on-size-change", false, "download episodes again if their size differs from the feed's by more than 5%")
+var noRedownloadDeleted = flag.Bool("no-redownload-deleted", false, "record downloaded episodes in the GUID database, and don't download them again if they're deleted")
+var markAllDownloaded = flag.Bool("mark-all-downloaded", false, "record all episodes in the GUID database without downloading them")
+var guidDBPath = flag.String("guid-db", "", "file to record downloaded episodes in, default .podget-guids in the destination directory")
+var concurrentFeeds = flag.Int("concurrent-feeds", 1, "number of feeds to fetch at once")
+var concurrentDownloads = flag.Int("concurrent-downloads", 1, "number of episodes to download at once; high values of this and -concurrent-feeds can overload servers and disks")
+var crossPlatform = flag.Bool("cross-platform", false, "limit file and directory names to 255 bytes so archives can be shared with Windows")
+var preferType = flag.String("prefer-type", "", "MIME type such as audio/opus to download from podcast:alternateEnclosure when available")
+var episodeRangeFlag = flag.String("episode-range", "", "only download episodes FROM-TO, counting from 1 in -episode-order after other filters; FROM- or -COUNT for the last COUNT")
+var episodeOrder = flag.String("episode-order", "feed", "order -episode-range counts episodes in: "+strings.Join(episodeOrders, ", "))
+var outputFormat = flag.String("output-format", "", "list the feeds' episodes as csv or json, or the feeds as opml, instead of downloading")
+var infoMode = flag.Bool("info", false, "print a summary of each feed instead of downloading")
 
-var podtracRE *regexp.Regexp
-var podtracField string
+// processFeed processes the feed, returning the channel, or nil if it
+// couldn't be fetched. With -follow-pages, all the pages are fetched and merged
+// before any episodes are processed, so that episode numbers and
+// -episode-range count across the whole feed rather than starting again on
+// each page.
+func (r *Runner) processFeed(src feedSource) *podcast.Channel {
+	report := newFeedReport(r.ReportFile, src.URL)
+	defer report.fetched()
+	channel, err := r.fetchAll(src.URL)
+	if err != nil {
+		logError("can't process %s: %v", src.URL, err)
+		return nil
+	}
+	r.processChannel(channel, src.Dir, report)
+	return channel
+}
 
-func processFeed(feedurl string) {
-	resp, err := http.Get(feedurl)
+// fetchAll fetches the channel at the given URL, along with any next pages if
+// -follow-pages is set, and merges them into a single channel.
+func (r *Runner) fetchAll(feedurl string) (*podcast.Channel, error) {
+	channel, err := r.fetchChannel(feedurl)
 	if err != nil {
-		logError("can't fetch feed %s: %v", feedurl, err)
-		return
+		return nil, err
 	}
-	defer resp.Body.Close()
-	xmlb, err := ioutil.ReadAll(resp.Body)
+	visited := map[string]bool{feedurl: true}
+	for pageurl := nextPage(feedurl, channel); r.FollowPages && pageurl != "" && !visited[pageurl]; {
+		visited[pageurl] = true
+		logInfo("following next page %s", pageurl)
+		page, err := r.fetchChannel(pageurl)
+		if err != nil {
+			logError("can't process %s: %v", pageurl, err)
+			break
+		}
+		channel = channel.Merge(page)
+		pageurl = nextPage(pageurl, page)
+	}
+	return channel, nil
+}
+
+// nextPage returns the absolute URL of the channel's next page, or an empty
+// string if there isn't one.
+func nextPage(feedurl string, channel *podcast.Channel) string {
+	if channel.NextPageURL == "" {
+		return ""
+	}
+	next, err := url.Parse(channel.NextPageURL)
 	if err != nil {
-		logError("error reading response from %s: %v", feedurl, err)
-		return
+		logError("can't parse next page URL %s: %v", channel.NextPageURL, err)
+		return ""
 	}
-	err = processChannel(xmlb)
+	base, _ := url.Parse(feedurl)
+	return base.ResolveReference(next).String()
+}
+
+// fetchFeeds processes the feeds, up to -concurrent-feeds at a time, and
+// returns them in the same order.
+func (r *Runner) fetchFeeds(sources []feedSource) []fetchedFeed {
+	feeds := make([]fetchedFeed, len(sources))
+	slots := make(chan struct{}, atLeastOne(r.ConcurrentFeeds))
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, src feedSource) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			logInfo("fetching %s", src.URL)
+			feeds[i] = fetchedFeed{URL: src.URL, Channel: r.processFeed(src)}
+		}(i, src)
+	}
+	wg.Wait()
+	return feeds
+}
+
+// isFeedType reports whether a Content-Type header value is one of the types
+// used for RSS, Atom or JSON feeds.
+func isFeedType(ctype string) bool {
+	mediatype, _, err := mime.ParseMediaType(ctype)
 	if err != nil {
-		logError("can't process %s: %v", feedurl, err)
+		return false
+	}
+	switch mediatype {
+	case "application/rss+xml", "text/xml", "application/xml",
+		"application/atom+xml", "application/feed+json", "application/json":
+		return true
 	}
+	return false
 }
 
-func podtracCompile() error {
-	var err error
-	instruction := *podtrac
+// atLeastOne returns n, or 1 if n is less than 1, for counts of workers where
+// zero would mean nothing ever happens.
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// fetchChannel fetches and parses the feed at the given URL.
+func (r *Runner) fetchChannel(feedurl string) (*podcast.Channel, error) {
+	req, err := newFeedRequest(context.Background(), http.MethodGet, feedurl)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("server returned HTTP %d", resp.StatusCode)
+	}
+	if ctype := resp.Header.Get("Content-Type"); !isFeedType(ctype) {
+		logWarn("feed %s has unexpected content type %s", feedurl, ctype)
+	}
+	var body io.Reader = resp.Body
+	if r.MaxFeedSize > 0 {
+		body = &limitedReader{r: body, n: r.MaxFeedSize}
+	}
+	channel, err := r.parseChannel(body)
+	if errors.Is(err, errFeedTooLarge) {
+		return nil, fmt.Errorf("feed is too large, limit is %d bytes", r.MaxFeedSize)
+	}
+	return channel, err
+}
+
+// podtracCompile parses a -podtrac instruction, which gives a field name and
+// a regexp. It returns nil if the instruction is empty.
+func podtracCompile(instruction string) (*podtracRule, error) {
 	if instruction == "" {
-		return nil
+		return nil, nil
 	}
 	chunks := strings.SplitN(instruction, " ", 2)
-	podtracField = strings.TrimSpace(chunks[0])
+	if len(chunks) < 2 {
+		return nil, fmt.Errorf("expected a field name and a regexp, got %s", instruction)
+	}
+	rule := &podtracRule{field: strings.TrimSpace(chunks[0])}
+	known := false
+	for _, f := range podtracFields {
+		known = known || f == rule.field
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown field %s, must be one of %s", rule.field, strings.Join(podtracFields, ", "))
+	}
 	sregex := strings.Trim(chunks[1], " /")
 	if *debug {
 		logDebug("compiling %s", sregex)
 	}
-	podtracRE, err = regexp.Compile(sregex)
-	return err
+	re, err := regexp.Compile(sregex)
+	if err != nil {
+		return nil, err
+	}
+	rule.re = re
+	return rule, nil
 }
 
 func main() {
 	flag.Parse()
 
-	if err := podtracCompile(); err != nil {
+	r := newRunner()
+
+	if rule, err := podtracCompile(*podtrac); err != nil {
 		logError("can't compile podtrac decode instruction: %v", err)
 		os.Exit(1)
-	} else {
-		logDebug("will search field %s for %s", podtracField, podtracRE)
+	} else if rule != nil {
+		logDebug("will search field %s for %s", rule.field, rule.re)
+		r.Podtrac = rule
+	}
+
+	if *slugMode != "transliterate" && *slugMode != "ascii-only" {
+		logError("unknown -slug-mode %s, must be transliterate or ascii-only", *slugMode)
+		os.Exit(1)
+	}
+
+	known := false
+	for _, ds := range dirStrategies {
+		known = known || ds == *dirStrategy
+	}
+	if !known {
+		logError("unknown -dir-strategy %s, must be one of %s", *dirStrategy, strings.Join(dirStrategies, ", "))
+		os.Exit(1)
+	}
+	if !r.perFeedDirs() && (*keepN > 0 || *quota != "" || *symlinkLatest) {
+		logError("-keep-n, -quota and -symlink-latest need -dir-strategy feed, as -dir-strategy %s puts several feeds in one directory", *dirStrategy)
+		os.Exit(1)
 	}
 
-	wg := new(sync.WaitGroup)
+	if *concurrentFeeds < 1 || *concurrentDownloads < 1 {
+		logError("-concurrent-feeds and -concurrent-downloads must be at least 1")
+		os.Exit(1)
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		downloader()
-	}()
+	if *outputFormat != "" {
+		known := false
+		for _, f := range outputFormats {
+			known = known || f == *outputFormat
+		}
+		if !known {
+			logError("unknown -output-format %s, must be one of %s", *outputFormat, strings.Join(outputFormats, ", "))
+			os.Exit(1)
+		}
+	}
 
-	wg.Add(1)
-	go func() {
-		for _, feedurl := range flag.Args() {
-			logInfo("fetching %s", feedurl)
-			defer wg.Done()
-			processFeed(feedurl)
+	if *zeroDurationPolicy != "skip" && *zeroDurationPolicy != "download" {
+		logError("unknown -zero-duration-policy %s, must be skip or download", *zeroDurationPolicy)
+		os.Exit(1)
+	}
+
+	if err := parseSizeFlag("min-size", *minSize, &r.MinSize); err != nil {
+		logError("%v", err)
+		os.Exit(1)
+	}
+	if err := parseSizeFlag("max-size", *maxSize, &r.MaxSize); err != nil {
+		logError("%v", err)
+		os.Exit(1)
+	}
+
+	if err := r.compileEpisodeTemplate(*episodeTemplateFlag); err != nil {
+		logError("can't parse -episode-template: %v", err)
+		os.Exit(1)
+	}
+
+	if err := parseSizeFlag("quota", *quota, &r.Quota); err != nil {
+		logError("%v", err)
+		os.Exit(1)
+	}
+
+	known = false
+	for _, eo := range episodeOrders {
+		known = known || eo == *episodeOrder
+	}
+	if !known {
+		logError("unknown -episode-order %s, must be one of %s", *episodeOrder, strings.Join(episodeOrders, ", "))
+		os.Exit(1)
+	}
+
+	if *episodeRangeFlag != "" {
+		er, err := parseEpisodeRange(*episodeRangeFlag)
+		if err != nil {
+			logError("%v", err)
+			os.Exit(1)
 		}
-		close(dlqueue)
-	}()
-	wg.Wait()
+		r.EpisodeRange = er
+	}
+
+	// -keep-n would otherwise download the episodes it deleted again next time
+	if *noRedownloadDeleted || *markAllDownloaded || r.KeepN > 0 {
+		if *guidDBPath == "" {
+			*guidDBPath = filepath.Join(r.DestDir, ".podget-guids")
+		}
+		db, err := openGUIDDB(*guidDBPath)
+		if err != nil {
+			logError("can't open GUID database: %v", err)
+			os.Exit(1)
+		}
+		r.guids = db
+	}
+
+	if *rateLimit != "" {
+		limit, err := parseSize(*rateLimit)
+		if err != nil || limit == 0 {
+			logError("invalid -rate-limit %s, must be a size such as 500KB", *rateLimit)
+			os.Exit(1)
+		}
+		r.bandwidth = newRateLimiter(limit)
+	}
+
+	if *discoverMode {
+		r.discover(flag.Args())
+		return
+	}
+
+	sources, err := r.feedSources(flag.Args())
+	if err != nil {
+		logError("%v", err)
+		os.Exit(1)
+	}
+
+	if *infoMode {
+		r.showInfo(feedURLs(sources))
+		return
+	}
+
+	if *outputFormat != "" {
+		if err := r.writeOutput(feedURLs(sources), *outputFormat); err != nil {
+			logError("can't write output: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	r.locks = newDirLocks(r.DestDir)
+	if err := r.locks.lockSources(sources); err != nil {
+		logError("%v", err)
+		os.Exit(1)
+	}
+	defer r.locks.Close()
+
+	r.downloads = newDownloader(atLeastOne(r.ConcurrentDownloads), r.InterDownloadDelay, r.downloader)
+
+	if *daemon {
+		r.runDaemon(flag.Args(), sources)
+	}
+
+	feeds := r.fetchFeeds(sources)
+	validations.Wait()
+	r.downloads.Close()
+	if r.ExportOPML != "" {
+		exportOPMLFile(r.ExportOPML, feeds)
+	}
+	r.downloads.Wait()
 
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var enclosureHost = regexp.MustCompile(`(<enclosure[^>]*url=")https?://[^/"]+/`)
+
+// newFeedServer returns a server which serves the feeds in testdata under
+// /feeds/, with their enclosure URLs changed to point to /media/ on the same
+// server. Each media file contains its own path.
+func newFeedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/feeds/", func(w http.ResponseWriter, req *http.Request) {
+		data, err := os.ReadFile(filepath.Join("..", "..", "testdata", strings.TrimPrefix(req.URL.Path, "/feeds/")))
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(enclosureHost.ReplaceAll(data, []byte("${1}"+srv.URL+"/media/")))
+	})
+	mux.HandleFunc("/media/", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte(req.URL.Path))
+	})
+	return srv
+}
+
+// downloadedFiles returns the paths of the files under dir, relative to dir
+// and with forward slashes, sorted.
+func downloadedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestFetchFeeds(t *testing.T) {
+	srv := newFeedServer(t)
+	tests := []struct {
+		name  string
+		feeds []string
+		setup func(r *Runner)
+		want  []string
+	}{
+		{
+			name:  "RSS 2.0",
+			feeds: []string{"standard.xml"},
+			want:  []string{"Plain_RSS/1.mp3", "Plain_RSS/2.mp3"},
+		},
+		{
+			name:  "iTunes",
+			feeds: []string{"talpodcast.xml"},
+			want: []string{
+				"This_American_Life/175.mp3",
+				"This_American_Life/472.mp3",
+				"This_American_Life/622.mp3",
+				"This_American_Life/623.mp3",
+			},
+		},
+		{
+			name:  "several feeds",
+			feeds: []string{"standard.xml", "minimal.xml", "empty.xml"},
+			want: []string{
+				"Empty_Elements/empty.mp3",
+				"Minimal/only.mp3",
+				"Plain_RSS/1.mp3",
+				"Plain_RSS/2.mp3",
+			},
+		},
+		{
+			name:  "no episodes",
+			feeds: []string{"noitems.xml"},
+		},
+		{
+			name:  "feed not found",
+			feeds: []string{"missing.xml", "minimal.xml"},
+			want:  []string{"Minimal/only.mp3"},
+		},
+		{
+			name:  "episode range",
+			feeds: []string{"talpodcast.xml"},
+			setup: func(r *Runner) {
+				r.EpisodeRange, _ = parseEpisodeRange("2-3")
+			},
+			want: []string{"This_American_Life/472.mp3", "This_American_Life/622.mp3"},
+		},
+		{
+			name:  "flat",
+			feeds: []string{"standard.xml"},
+			setup: func(r *Runner) {
+				r.DirStrategy = "flat"
+			},
+			want: []string{"Plain_RSS_1.mp3", "Plain_RSS_2.mp3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Runner{DestDir: t.TempDir(), ConcurrentFeeds: 2}
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+			var args []string
+			for _, feed := range tt.feeds {
+				args = append(args, srv.URL+"/feeds/"+feed)
+			}
+			sources, err := r.feedSources(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.downloads = newDownloader(1, 0, r.downloader)
+			r.fetchFeeds(sources)
+			r.downloads.Close()
+			r.downloads.Wait()
+
+			got := downloadedFiles(t, r.DestDir)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downloaded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchFeedsContent(t *testing.T) {
+	srv := newFeedServer(t)
+	r := &Runner{DestDir: t.TempDir()}
+	sources, err := r.feedSources([]string{srv.URL + "/feeds/minimal.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.downloads = newDownloader(1, 0, r.downloader)
+	feeds := r.fetchFeeds(sources)
+	r.downloads.Close()
+	r.downloads.Wait()
+
+	if len(feeds) != 1 || feeds[0].Channel == nil || feeds[0].Channel.Title != "Minimal" {
+		t.Fatalf("fetchFeeds returned %+v", feeds)
+	}
+	data, err := os.ReadFile(filepath.Join(r.DestDir, "Minimal", "only.mp3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/media/minimal/only.mp3"; string(data) != want {
+		t.Errorf("downloaded %q, want %q", data, want)
+	}
+}
+
+// tailReader fails the read, and records that it was asked for data.
+type tailReader struct {
+	read bool
+}
+
+func (tr *tailReader) Read(p []byte) (int, error) {
+	tr.read = true
+	return 0, errors.New("read past the end of the feed")
+}
+
+func TestParseChannelStreams(t *testing.T) {
+	const items = 2000
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Long</title>` +
+		strings.Repeat(`<item><title>Episode</title><enclosure url="http://example.com/e.mp3" length="1024" type="audio/mpeg"/></item>`, items) +
+		`</channel></rss>`
+	tail := &tailReader{}
+	r := &Runner{}
+	channel, err := r.parseChannel(io.MultiReader(strings.NewReader(feed), tail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channel.Item) != items {
+		t.Errorf("got %d items, want %d", len(channel.Item), items)
+	}
+	if tail.read {
+		t.Errorf("feed was read past the end of the rss element")
+	}
+}
+
+func TestParseChannelLenient(t *testing.T) {
+	feed := "<?xml version=\"1.0\"?>\n<rss version=\"2.0\"><channel><title>Nul\x00 Feed</title>" +
+		`<item><title>Episode</title><enclosure url="http://example.com/e.mp3" length="1024" type="audio/mpeg"/></item>` +
+		"</channel></rss>"
+	strict := &Runner{}
+	if _, err := strict.parseChannel(strings.NewReader(feed)); err == nil {
+		t.Errorf("strict parse succeeded, want error")
+	}
+	lenient := &Runner{Lenient: true}
+	channel, err := lenient.parseChannel(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("lenient parse: %v", err)
+	}
+	if channel.Title != "Nul Feed" || len(channel.Item) != 1 {
+		t.Errorf("got title %q and %d items, want Nul Feed and 1", channel.Title, len(channel.Item))
+	}
+	// The strict parse stops at the NUL, so the retry has to use both the
+	// copy of what it read and the rest of the input
+	const items = 2000
+	long := "<rss><channel><title>Long</title>" +
+		strings.Repeat(`<item><title>Episode</title></item>`, items) +
+		"<item><title>Last\x00</title></item>" +
+		strings.Repeat(`<item><title>Episode</title></item>`, items) +
+		"</channel></rss>"
+	channel, err = lenient.parseChannel(strings.NewReader(long))
+	if err != nil {
+		t.Fatalf("lenient parse: %v", err)
+	}
+	if len(channel.Item) != 2*items+1 || channel.Item[items].Title != "Last" {
+		t.Errorf("got %d items, want %d with Last in the middle", len(channel.Item), 2*items+1)
+	}
+}
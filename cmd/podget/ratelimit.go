@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Size suffixes accepted by parseSize, longest first so that KiB isn't read
+// as KB
+var sizeUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	{"B", 1},
+}
+
+// parseSize parses a human-friendly size such as 500KB, 1.5GB or 2MiB into a
+// number of bytes. KB, MB and so on are powers of 1000; KiB, MiB and so on are
+// powers of 1024. A bare number is a number of bytes.
+func parseSize(s string) (int64, error) {
+	num := strings.TrimSpace(s)
+	mult := 1.0
+	upper := strings.ToUpper(num)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			num = strings.TrimSpace(num[:len(num)-len(u.suffix)])
+			mult = u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("can't parse %s as a size", s)
+	}
+	return int64(f * mult), nil
+}
+
+// parseSizeFlag parses the value of a size flag into n, leaving n unchanged if
+// the flag wasn't set.
+func parseSizeFlag(name string, value string, n *int64) error {
+	if value == "" {
+		return nil
+	}
+	size, err := parseSize(value)
+	if err != nil {
+		return fmt.Errorf("invalid -%s: %v", name, err)
+	}
+	*n = size
+	return nil
+}
+
+// rateLimiter is a token bucket shared between downloads, limiting their
+// total speed. The bucket holds up to a second's worth of bytes.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Bytes per second
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: time.Now()}
+}
+
+// wait takes n bytes' worth of tokens from the bucket, sleeping until the
+// bucket has refilled enough to allow them.
+func (rl *rateLimiter) wait(n int) {
+	rl.mu.Lock()
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.rate {
+		rl.tokens = rl.rate
+	}
+	rl.last = now
+	rl.tokens -= float64(n)
+	var delay time.Duration
+	if rl.tokens < 0 {
+		delay = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	rl.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// rateLimitedReader limits the speed at which r can be read.
+type rateLimitedReader struct {
+	r  io.Reader
+	rl *rateLimiter
+}
+
+func (rlr *rateLimitedReader) Read(p []byte) (int, error) {
+	// Don't read more than the bucket can hold, so the limit stays smooth
+	if max := int(rlr.rl.rate); len(p) > max && max > 0 {
+		p = p[:max]
+	}
+	n, err := rlr.r.Read(p)
+	rlr.rl.wait(n)
+	return n, err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// reportRecord is one line of the -report-file.
+type reportRecord struct {
+	Timestamp          time.Time `json:"timestamp"`
+	FeedURL            string    `json:"feed_url"`
+	EpisodesDownloaded int       `json:"episodes_downloaded"`
+	EpisodesSkipped    int       `json:"episodes_skipped"`
+	EpisodesFailed     int       `json:"episodes_failed"`
+	BytesDownloaded    int64     `json:"bytes_downloaded"`
+	DurationSeconds    float64   `json:"duration_seconds"`
+}
+
+// feedReport collects the results of fetching a feed and downloading its
+// episodes. Once the feed has been fetched and all its downloads have
+// finished, a record is appended to the -report-file. All the methods do
+// nothing if called on a nil feedReport, which is what newFeedReport returns
+// if there's no -report-file.
+type feedReport struct {
+	mu      sync.Mutex
+	path    string // The -report-file
+	start   time.Time
+	pending int  // Downloads queued but not finished
+	done    bool // Feed has been fetched and processed
+	record  reportRecord
+}
+
+// newFeedReport returns a report on the feed to be appended to the file at
+// path, or nil if path is empty.
+func newFeedReport(path string, feedurl string) *feedReport {
+	if path == "" {
+		return nil
+	}
+	now := time.Now()
+	return &feedReport{path: path, start: now, record: reportRecord{Timestamp: now, FeedURL: feedurl}}
+}
+
+func (fr *feedReport) skipped() {
+	if fr == nil {
+		return
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.record.EpisodesSkipped++
+}
+
+func (fr *feedReport) queued() {
+	if fr == nil {
+		return
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.pending++
+}
+
+// finished records the result of a download of n bytes.
+func (fr *feedReport) finished(n int64, err error) {
+	if fr == nil {
+		return
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.pending--
+	if err != nil {
+		fr.record.EpisodesFailed++
+	} else {
+		fr.record.EpisodesDownloaded++
+		fr.record.BytesDownloaded += n
+	}
+	fr.writeIfComplete()
+}
+
+// fetched records that all the episodes of the feed have been processed.
+func (fr *feedReport) fetched() {
+	if fr == nil {
+		return
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.done = true
+	fr.writeIfComplete()
+}
+
+var reportMu sync.Mutex
+
+// writeIfComplete appends the record to the -report-file if the feed has been
+// processed and there are no downloads left. The caller must hold fr.mu.
+func (fr *feedReport) writeIfComplete() {
+	if !fr.done || fr.pending > 0 {
+		return
+	}
+	fr.record.DurationSeconds = time.Since(fr.start).Seconds()
+	line, err := json.Marshal(fr.record)
+	if err != nil {
+		logError("can't encode report: %v", err)
+		return
+	}
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	f, err := os.OpenFile(fr.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		logError("can't open report file %s: %v", fr.path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logError("can't write report file %s: %v", fr.path, err)
+	}
+}
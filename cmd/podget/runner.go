@@ -0,0 +1,112 @@
+package main
+
+import (
+	"text/template"
+	"time"
+)
+
+// Runner fetches feeds and downloads their episodes. Its configuration is
+// normally taken from the command line flags by newRunner, but a Runner can
+// also be built directly. The zero value of most fields turns the option
+// off; the exception is MaxFutureDays, where zero skips any episode dated in
+// the future.
+type Runner struct {
+	AllowExplicit         bool               // Overrides FilterExplicit for feeds
+	Chapters              bool               // Download chapter files
+	ConcurrentDownloads   int                // Number of episodes to download at once
+	ConcurrentFeeds       int                // Number of feeds to fetch at once
+	ConflictSuffix        string             // Added to filenames used by more than one feed
+	CrossPlatform         bool               // Limit names to what Windows allows
+	DestDir               string             // Download directory for feeds not given one
+	DirStrategy           string             // How to arrange downloads, one of dirStrategies
+	EpisodeOrder          string             // Order EpisodeRange counts in, one of episodeOrders
+	EpisodeRange          *episodeRange      // Positions of the episodes to download, nil for all
+	EpisodeTemplate       *template.Template // Filename template for numbered episodes, nil for none
+	ExportOPML            string             // File to write an OPML list of the feeds to
+	FeedsFile             string             // File listing feeds, reloaded on SIGHUP in daemon mode
+	FilterExplicit        bool               // Skip explicit feeds and episodes
+	FollowPages           bool               // Follow RFC 5005 next page links
+	InterDownloadDelay    time.Duration      // Pause between downloads
+	Interval              time.Duration      // Time between fetches in daemon mode
+	KeepGrace             time.Duration      // Age below which KeepN doesn't delete files
+	KeepN                 int                // Episodes to keep in each feed directory, 0 for all
+	Lenient               bool               // Strip invalid characters from feeds
+	MarkAllDownloaded     bool               // Record episodes in the GUID database instead of downloading them
+	MaxDuration           time.Duration      // Skip longer episodes, 0 for no limit
+	MaxFeedSize           int64              // Largest feed to parse, in bytes
+	MaxFutureDays         int                // Skip episodes dated further in the future, negative to disable
+	MaxSize               int64              // Skip larger enclosures, 0 for no limit
+	MIMEFilter            string             // Comma-separated MIME type prefixes to download
+	MinDuration           time.Duration      // Skip shorter episodes
+	MinSize               int64              // Skip smaller enclosures
+	NumberEpisodes        bool               // Prefix filenames with episode numbers
+	OverwriteOnSizeChange bool               // Download again if the size has changed
+	Podtrac               *podtracRule       // How to extract episode numbers from podtrac feeds, nil for none
+	PodtracFallback       bool               // Use the URL filename if Podtrac doesn't match
+	PreferType            string             // MIME type of alternate enclosure to download if available
+	ProbeDurations        bool               // Check HTTP headers for missing durations
+	Quota                 int64              // Max size of each feed directory in bytes, 0 for no limit
+	QuotaDryRun           bool               // Print the files Quota would delete instead of deleting them
+	RerunDays             int                // Age in days after which existing files are downloaded again, 0 to disable
+	ReportFile            string             // File to append a JSON summary of each feed to
+	Retries               int                // Retries after a transient download error
+	SkipZeroDuration      bool               // Skip episodes of unknown duration
+	SlugMode              string             // How to make directory names, transliterate or ascii-only
+	SlugPlaceholder       string             // Replacement for characters which can't be transliterated
+	SymlinkLatest         bool               // Link latest.<ext> to the newest episode
+	TranscriptFormat      string             // Preferred transcript format
+	Transcripts           bool               // Download transcripts
+	Validate              bool               // Check enclosure URLs in the background
+
+	downloads *Downloader
+	locks     *dirLocks    // nil if the download directories aren't locked
+	guids     *guidDB      // nil unless the GUID database is enabled
+	bandwidth *rateLimiter // nil unless -rate-limit is set
+}
+
+// newRunner returns a Runner configured from the command line flags. Flags
+// which need parsing, such as sizes and templates, are set by main.
+func newRunner() *Runner {
+	return &Runner{
+		AllowExplicit:         *allowExplicit,
+		Chapters:              *chapters,
+		ConcurrentDownloads:   *concurrentDownloads,
+		ConcurrentFeeds:       *concurrentFeeds,
+		ConflictSuffix:        *conflictSuffix,
+		CrossPlatform:         *crossPlatform,
+		DestDir:               *destdir,
+		DirStrategy:           *dirStrategy,
+		EpisodeOrder:          *episodeOrder,
+		ExportOPML:            *exportOPML,
+		FeedsFile:             *feedsFile,
+		FilterExplicit:        *filterExplicit,
+		FollowPages:           *followPages,
+		InterDownloadDelay:    *interDownloadDelay,
+		Interval:              *interval,
+		KeepGrace:             *keepGrace,
+		KeepN:                 *keepN,
+		Lenient:               *lenient,
+		MarkAllDownloaded:     *markAllDownloaded,
+		MaxDuration:           *maxDuration,
+		MaxFeedSize:           *maxFeedSize,
+		MaxFutureDays:         *maxFutureDays,
+		MIMEFilter:            *mimeFilter,
+		MinDuration:           *minDuration,
+		NumberEpisodes:        *numberEpisodes,
+		OverwriteOnSizeChange: *overwriteOnSizeChange,
+		PodtracFallback:       *podtracFallback,
+		PreferType:            *preferType,
+		ProbeDurations:        *probeDurations,
+		QuotaDryRun:           *quotaDryRun,
+		RerunDays:             *maxdays,
+		ReportFile:            *reportFile,
+		Retries:               *retries,
+		SkipZeroDuration:      *zeroDurationPolicy == "skip",
+		SlugMode:              *slugMode,
+		SlugPlaceholder:       *slugPlaceholder,
+		SymlinkLatest:         *symlinkLatest,
+		TranscriptFormat:      *transcriptFormat,
+		Transcripts:           *transcripts,
+		Validate:              *validate,
+	}
+}
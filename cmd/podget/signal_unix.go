@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fetchNowSignals make daemon mode fetch all feeds immediately.
+var fetchNowSignals = []os.Signal{syscall.SIGUSR1}
+
+// reloadSignals make daemon mode reread the -feeds file.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+// fetchNowSignals make daemon mode fetch all feeds immediately. Windows has
+// no equivalent of SIGUSR1.
+var fetchNowSignals []os.Signal
+
+// reloadSignals make daemon mode reread the -feeds file. Windows has no
+// equivalent of SIGHUP.
+var reloadSignals []os.Signal
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lpar/podtools/podcast"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters which don't decompose into an ASCII letter plus accents.
+var ligatures = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D",
+	"ð", "d", "Ð", "D", "þ", "th", "Þ", "Th", "ı", "i",
+)
+
+// stripMarks returns a transformer which decomposes accented characters and
+// removes the accents. Transformers hold state, so each call to transliterate
+// needs its own.
+func stripMarks() transform.Transformer {
+	return transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+}
+
+// transliterate converts Latin characters with diacritics to their plain
+// ASCII equivalents, so é becomes e and ß becomes ss. Other non-ASCII
+// characters, such as CJK or Arabic, are replaced with placeholder, or kept
+// as-is if placeholder is empty.
+func transliterate(s string, placeholder string) string {
+	s = ligatures.Replace(s)
+	if t, _, err := transform.String(stripMarks(), s); err == nil {
+		s = t
+	}
+	if placeholder == "" {
+		return s
+	}
+	return replaceNonASCII(s, placeholder)
+}
+
+// replaceNonASCII replaces each run of non-ASCII characters with placeholder.
+func replaceNonASCII(s string, placeholder string) string {
+	var sb strings.Builder
+	inRun := false
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			if !inRun {
+				sb.WriteString(placeholder)
+			}
+			inRun = true
+			continue
+		}
+		inRun = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// slugify converts a feed title to a directory name according to the
+// -slug-mode flag.
+func (r *Runner) slugify(title string) string {
+	var name string
+	switch r.SlugMode {
+	case "ascii-only":
+		name = asciiOnly.ReplaceAllLiteralString(title, "")
+	default:
+		name = transliterate(title, r.SlugPlaceholder)
+	}
+	return r.sanitizeName(strings.ReplaceAll(name, " ", "_"))
+}
+
+// Longest file or directory name NTFS allows, in bytes
+const maxNameBytes = 255
+
+// sanitizeName makes s safe to use as a file or directory name. Characters
+// which aren't allowed on Windows are always replaced. With -cross-platform,
+// the name is also truncated to maxNameBytes, so that an archive can be
+// copied or shared to Windows systems.
+func (r *Runner) sanitizeName(s string) string {
+	s = podcast.SanitizePathComponent(s)
+	if r.CrossPlatform {
+		s = podcast.TruncatePathComponent(s, maxNameBytes)
+	}
+	return s
+}
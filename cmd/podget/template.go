@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"text/template"
+	"time"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// episodeData is the data available to -episode-template.
+type episodeData struct {
+	Title   string
+	Season  int
+	Episode int
+	Date    time.Time
+}
+
+// Matches the {{.Field:02d}} shorthand for {{printf "%02d" .Field}}
+var formatShorthand = regexp.MustCompile(`\{\{\s*(\.\w+):(\w+)\s*\}\}`)
+
+// compileEpisodeTemplate parses the -episode-template flag. As well as the
+// usual text/template syntax, {{.Season:02d}} may be used as shorthand for
+// {{printf "%02d" .Season}}, and the slug function makes a string safe to use
+// in a filename.
+func (r *Runner) compileEpisodeTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	text = formatShorthand.ReplaceAllString(text, `{{printf "%$2" $1}}`)
+	tmpl, err := template.New("episode").Funcs(template.FuncMap{
+		"slug": r.slugify,
+	}).Parse(text)
+	if err != nil {
+		return err
+	}
+	r.EpisodeTemplate = tmpl
+	return nil
+}
+
+// episodeFilename formats the filename for an item using -episode-template.
+// It returns false if there's no template or the item has no episode number,
+// in which case the usual filename should be used.
+func (r *Runner) episodeFilename(item *podcast.Item, ext string) (string, bool, error) {
+	if r.EpisodeTemplate == nil || item.Episode == 0 {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	err := r.EpisodeTemplate.Execute(&buf, episodeData{
+		Title:   item.Title,
+		Season:  item.Season,
+		Episode: item.Episode,
+		Date:    item.PubDate.Time,
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return r.sanitizeName(buf.String() + ext), true, nil
+}
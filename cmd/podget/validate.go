@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lpar/podtools/podcast"
+)
+
+// Max number of HEAD requests made at once by -validate
+const maxValidations = 10
+
+var validationSlots = make(chan struct{}, maxValidations)
+var validations sync.WaitGroup
+
+// validateEnclosure checks the enclosure URL in the background with a HEAD
+// request, and warns if the response suggests the download will fail or
+// isn't what the feed says it is. It doesn't stop the download being queued.
+func validateEnclosure(item *podcast.Item, enc *podcast.Enclosure) {
+	validations.Add(1)
+	go func() {
+		defer validations.Done()
+		validationSlots <- struct{}{}
+		defer func() { <-validationSlots }()
+		req, err := newRequest(context.Background(), http.MethodHead, enc.URL)
+		if err != nil {
+			logWarn("can't validate %s: %v", enc.URL, err)
+			return
+		}
+		resp, err := feedClient.Do(req)
+		if err != nil {
+			logWarn("can't validate %s: %v", enc.URL, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			logWarn("enclosure for %s returned HTTP %d: %s", item.Title, resp.StatusCode, enc.URL)
+			return
+		}
+		ctype := strings.ToLower(resp.Header.Get("Content-Type"))
+		if !strings.HasPrefix(ctype, "audio/") && !strings.HasPrefix(ctype, "video/") {
+			logWarn("enclosure for %s has content type %s, not audio or video: %s", item.Title, ctype, enc.URL)
+		}
+		if enc.Length > 0 && resp.ContentLength > 0 && enc.Length != resp.ContentLength {
+			logWarn("enclosure for %s is %d bytes, feed says %d: %s", item.Title, resp.ContentLength, enc.Length, enc.URL)
+		}
+	}()
+}
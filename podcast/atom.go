@@ -0,0 +1,130 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+// Elements of Atom feeds, as defined by RFC 4287, which have equivalents in
+// RSS.
+
+type atomFeed struct {
+	XMLName  xml.Name      `xml:"http://www.w3.org/2005/Atom feed"`
+	Authors  []*atomPerson `xml:"author"`
+	Entries  []*atomEntry  `xml:"entry"`
+	Icon     string        `xml:"icon"`
+	Links    []*atomLink   `xml:"link"`
+	Logo     string        `xml:"logo"`
+	Rights   string        `xml:"rights"`
+	Subtitle string        `xml:"subtitle"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+}
+
+type atomEntry struct {
+	Authors   []*atomPerson `xml:"author"`
+	Content   string        `xml:"content"`
+	ID        string        `xml:"id"`
+	Links     []*atomLink   `xml:"link"`
+	Published string        `xml:"published"`
+	Summary   string        `xml:"summary"`
+	Title     string        `xml:"title"`
+	Updated   string        `xml:"updated"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Length int64  `xml:"length,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// atomLinkRel returns the first link with the given relation, treating a
+// missing relation as alternate, per the spec.
+func atomLinkRel(links []*atomLink, rel string) *atomLink {
+	for _, link := range links {
+		r := link.Rel
+		if r == "" {
+			r = "alternate"
+		}
+		if r == rel {
+			return link
+		}
+	}
+	return nil
+}
+
+func atomAuthor(authors []*atomPerson) string {
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseAtomTime parses an Atom date, returning a zero Timestamp if it's
+// missing or invalid.
+func parseAtomTime(s string) Timestamp {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return Timestamp{}
+	}
+	return Timestamp{t}
+}
+
+func parseAtom(r io.Reader) (*RSS, error) {
+	var feed atomFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	ch := &Channel{
+		Author:      atomAuthor(feed.Authors),
+		Copyright:   feed.Rights,
+		Description: feed.Subtitle,
+		Title:       feed.Title,
+	}
+	if link := atomLinkRel(feed.Links, "alternate"); link != nil {
+		ch.Link = link.Href
+	}
+	for _, link := range feed.Links {
+		if link.Rel == "self" || link.Rel == "next" {
+			ch.AtomLink = append(ch.AtomLink, &AtomLink{Href: link.Href, Rel: link.Rel, Type: link.Type})
+		}
+	}
+	if link := atomLinkRel(feed.Links, "next"); link != nil {
+		ch.NextPageURL = link.Href
+	}
+	if updated := parseAtomTime(feed.Updated); !updated.IsZero() {
+		ch.LastBuild = &updated
+	}
+	if img := firstNonEmpty(feed.Logo, feed.Icon); img != "" {
+		ch.Image = &Image{URL: img, Title: feed.Title, Link: ch.Link}
+	}
+	for _, entry := range feed.Entries {
+		item := &Item{
+			Author:      atomAuthor(entry.Authors),
+			Description: firstNonEmpty(entry.Summary, entry.Content),
+			Title:       entry.Title,
+			PubDate:     parseAtomTime(firstNonEmpty(entry.Published, entry.Updated)),
+		}
+		if entry.ID != "" {
+			item.Guid = &Guid{AttrIsPermaLink: "false", Text: entry.ID}
+		}
+		if link := atomLinkRel(entry.Links, "alternate"); link != nil {
+			item.Link = link.Href
+		}
+		if link := atomLinkRel(entry.Links, "enclosure"); link != nil {
+			item.Enclosure = &Enclosure{Length: link.Length, MIMEType: link.Type, URL: link.Href}
+		}
+		ch.Item = append(ch.Item, item)
+	}
+	return &RSS{AttrVersion: "2.0", Channel: ch}, nil
+}
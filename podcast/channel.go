@@ -0,0 +1,258 @@
+package podcast
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// itemKey returns the key used to decide whether two items are the same
+// episode: the GUID if there is one, otherwise the enclosure URL.
+func itemKey(item *Item) string {
+	if item.Guid != nil && item.Guid.Text != "" {
+		return item.Guid.Text
+	}
+	if item.Enclosure != nil {
+		return item.Enclosure.URL
+	}
+	return ""
+}
+
+// Merge returns a new channel containing the items from both channels, with
+// duplicates removed and sorted newest first. The channel-level metadata is
+// copied from the receiver.
+func (ch *Channel) Merge(other *Channel) *Channel {
+	merged := *ch
+	merged.Item = nil
+	seen := make(map[string]bool)
+	add := func(items []*Item) {
+		for _, item := range items {
+			key := itemKey(item)
+			if key != "" {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			merged.Item = append(merged.Item, item)
+		}
+	}
+	add(ch.Item)
+	if other != nil {
+		add(other.Item)
+	}
+	sort.SliceStable(merged.Item, func(i, j int) bool {
+		return merged.Item[i].PubDate.After(merged.Item[j].PubDate.Time)
+	})
+	return &merged
+}
+
+// SkipAt reports whether the channel's skipHours or skipDays say that
+// aggregators shouldn't fetch the feed at the given time. Hours and days are
+// interpreted in GMT, per the RSS 2.0 spec.
+func (ch *Channel) SkipAt(t time.Time) bool {
+	t = t.UTC()
+	for _, h := range ch.SkipHours {
+		if h == t.Hour() {
+			return true
+		}
+	}
+	for _, d := range ch.SkipDays {
+		if strings.EqualFold(strings.TrimSpace(d), t.Weekday().String()) {
+			return true
+		}
+	}
+	return false
+}
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// UpdateInterval returns how often the feed expects to be updated, according to
+// its sy:updatePeriod and sy:updateFrequency elements. For example, a period
+// of daily with a frequency of 2 means every 12 hours. It returns zero if the
+// feed doesn't say.
+func (ch *Channel) UpdateInterval() time.Duration {
+	if ch.UpdatePeriod == "" && ch.UpdateFrequency == 0 {
+		return 0
+	}
+	period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(ch.UpdatePeriod))]
+	if !ok {
+		period = updatePeriods["daily"]
+	}
+	if ch.UpdateFrequency > 1 {
+		period /= time.Duration(ch.UpdateFrequency)
+	}
+	return period
+}
+
+// LatestItem returns the most recently published item, or nil if no items
+// have a publication date.
+func (ch *Channel) LatestItem() *Item {
+	var latest *Item
+	for _, item := range ch.Item {
+		if !item.PubDate.IsZero() && (latest == nil || item.PubDate.After(latest.PubDate.Time)) {
+			latest = item
+		}
+	}
+	return latest
+}
+
+// OldestItem returns the earliest published item, or nil if no items have a
+// publication date.
+func (ch *Channel) OldestItem() *Item {
+	var oldest *Item
+	for _, item := range ch.Item {
+		if !item.PubDate.IsZero() && (oldest == nil || item.PubDate.Before(oldest.PubDate.Time)) {
+			oldest = item
+		}
+	}
+	return oldest
+}
+
+// GroupByMonth groups the items by the month they were published, in UTC,
+// using keys of the form 2006-01. Items without a publication date are grouped
+// under "unknown". Within each group, items are in the same order as in the
+// channel.
+func (ch *Channel) GroupByMonth() map[string][]*Item {
+	groups := make(map[string][]*Item)
+	for _, item := range ch.Item {
+		key := "unknown"
+		if !item.PubDate.IsZero() {
+			key = item.PubDate.UTC().Format("2006-01")
+		}
+		groups[key] = append(groups[key], item)
+	}
+	return groups
+}
+
+// GroupBySeason groups the items by their iTunes season number. Items without
+// a season are grouped under 0. Within each group, items are in the same order
+// as in the channel.
+func (ch *Channel) GroupBySeason() map[int][]*Item {
+	groups := make(map[int][]*Item)
+	for _, item := range ch.Item {
+		groups[item.Season] = append(groups[item.Season], item)
+	}
+	return groups
+}
+
+// DeduplicateByGUID removes all but the first item with each GUID from the
+// channel, keeping the remaining items in order. Items without a GUID are
+// kept. It returns the number of items removed.
+func (ch *Channel) DeduplicateByGUID() int {
+	return ch.deduplicate(func(item *Item) string {
+		if item.Guid == nil {
+			return ""
+		}
+		return strings.TrimSpace(item.Guid.Text)
+	})
+}
+
+// DeduplicateByURL removes all but the first item with each enclosure URL from
+// the channel, keeping the remaining items in order. Items without an
+// enclosure are kept. It returns the number of items removed.
+func (ch *Channel) DeduplicateByURL() int {
+	return ch.deduplicate(func(item *Item) string {
+		enc := item.anyEnclosure()
+		if enc == nil {
+			return ""
+		}
+		return strings.TrimSpace(enc.URL)
+	})
+}
+
+// deduplicate removes items whose key has already been seen, in place.
+// Items with an empty key are never removed.
+func (ch *Channel) deduplicate(key func(*Item) string) int {
+	seen := make(map[string]bool, len(ch.Item))
+	kept := ch.Item[:0]
+	for _, item := range ch.Item {
+		k := key(item)
+		if k != "" {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		kept = append(kept, item)
+	}
+	removed := len(ch.Item) - len(kept)
+	for i := len(kept); i < len(ch.Item); i++ {
+		ch.Item[i] = nil
+	}
+	ch.Item = kept
+	return removed
+}
+
+// anyEnclosure returns the item's enclosure, or failing that the enclosure
+// from its media:content, or nil if it has neither.
+func (item *Item) anyEnclosure() *Enclosure {
+	if item.Enclosure != nil {
+		return item.Enclosure
+	}
+	return item.MediaEnclosure()
+}
+
+// EstimatedStorageBytes returns the total size of the channel's enclosures,
+// according to their length attributes. Feeds often give inaccurate lengths,
+// so it's only an estimate.
+func (ch *Channel) EstimatedStorageBytes() int64 {
+	return ch.EstimatedNewBytes(nil)
+}
+
+// EstimatedNewBytes is like EstimatedStorageBytes, but leaves out enclosures
+// which have already been downloaded. They're matched by comparing the
+// enclosure's Filename with the base names of existingFiles.
+func (ch *Channel) EstimatedNewBytes(existingFiles []string) int64 {
+	existing := make(map[string]bool, len(existingFiles))
+	for _, file := range existingFiles {
+		existing[filepath.Base(file)] = true
+	}
+	var total int64
+	for _, item := range ch.Item {
+		enc := item.anyEnclosure()
+		if enc == nil {
+			continue
+		}
+		if len(existing) > 0 {
+			if name, err := enc.Filename(); err == nil && existing[name] {
+				continue
+			}
+		}
+		total += enc.Length
+	}
+	return total
+}
+
+// ItemsWithEnclosure returns the items which have an enclosure with a URL,
+// in the same order as in the channel.
+func (ch *Channel) ItemsWithEnclosure() []*Item {
+	return ch.filterItems(func(item *Item) bool {
+		return item.Enclosure != nil && item.Enclosure.URL != ""
+	})
+}
+
+// ItemsWithDuration returns the items which have a non-zero duration, in the
+// same order as in the channel.
+func (ch *Channel) ItemsWithDuration() []*Item {
+	return ch.filterItems(func(item *Item) bool {
+		return item.Duration != 0
+	})
+}
+
+func (ch *Channel) filterItems(keep func(*Item) bool) []*Item {
+	var items []*Item
+	for _, item := range ch.Item {
+		if keep(item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Decoder reads an RSS feed from an input stream, without needing to load the
+// whole document into memory first.
+type Decoder struct {
+	d *xml.Decoder
+}
+
+// NewDecoder returns a Decoder which reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{d: newNamespaceDecoder(r)}
+}
+
+// Decode reads tokens until it finds the <rss> root element, then decodes the
+// feed from it.
+func (dec *Decoder) Decode() (*RSS, error) {
+	for {
+		tok, err := dec.d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no rss element found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "rss" {
+			return nil, fmt.Errorf("expected rss element, found %s", start.Name.Local)
+		}
+		var rss RSS
+		err = dec.d.DecodeElement(&rss, &start)
+		if err != nil {
+			return nil, err
+		}
+		return &rss, nil
+	}
+}
@@ -0,0 +1,68 @@
+package podcast
+
+import (
+	"strings"
+	"time"
+)
+
+// EpisodeByGUID returns the item with the given GUID, or nil if there isn't
+// one. For repeated lookups, use BuildIndex.
+func (ch *Channel) EpisodeByGUID(guid string) *Item {
+	for _, item := range ch.Item {
+		if item.Guid != nil && strings.TrimSpace(item.Guid.Text) == guid {
+			return item
+		}
+	}
+	return nil
+}
+
+// ChannelIndex allows items to be looked up without scanning the whole
+// channel. It's a snapshot, and doesn't see items added to the channel after
+// it was built.
+type ChannelIndex struct {
+	byGUID  map[string]*Item
+	byTitle map[string][]*Item
+	byDate  map[string][]*Item
+}
+
+// Format of the keys of ChannelIndex.byDate
+const indexDateFormat = "2006-01-02"
+
+// BuildIndex returns an index of the channel's items. If several items have
+// the same GUID, the first is indexed.
+func (ch *Channel) BuildIndex() *ChannelIndex {
+	idx := &ChannelIndex{
+		byGUID:  make(map[string]*Item, len(ch.Item)),
+		byTitle: make(map[string][]*Item, len(ch.Item)),
+		byDate:  make(map[string][]*Item),
+	}
+	for _, item := range ch.Item {
+		if item.Guid != nil {
+			guid := strings.TrimSpace(item.Guid.Text)
+			if _, ok := idx.byGUID[guid]; !ok && guid != "" {
+				idx.byGUID[guid] = item
+			}
+		}
+		idx.byTitle[item.Title] = append(idx.byTitle[item.Title], item)
+		if !item.PubDate.IsZero() {
+			day := item.PubDate.UTC().Format(indexDateFormat)
+			idx.byDate[day] = append(idx.byDate[day], item)
+		}
+	}
+	return idx
+}
+
+// ByGUID returns the item with the given GUID, or nil if there isn't one.
+func (idx *ChannelIndex) ByGUID(guid string) *Item {
+	return idx.byGUID[guid]
+}
+
+// ByTitle returns the items with exactly the given title.
+func (idx *ChannelIndex) ByTitle(title string) []*Item {
+	return idx.byTitle[title]
+}
+
+// ByDate returns the items published on the same day as t, in UTC.
+func (idx *ChannelIndex) ByDate(t time.Time) []*Item {
+	return idx.byDate[t.UTC().Format(indexDateFormat)]
+}
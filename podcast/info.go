@@ -0,0 +1,135 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// FeedInfo holds the channel-level metadata of a feed, without the items.
+type FeedInfo struct {
+	Author      string      `xml:"author,omitempty"`
+	Category    []*Category `xml:"category,omitempty"`
+	Copyright   string      `xml:"copyright,omitempty"`
+	Description string      `xml:"description,omitempty"`
+	Explicit    string      `xml:"explicit,omitempty"`
+	Funding     []*Funding  `xml:"https://podcastindex.org/namespace/1.0 funding,omitempty"`
+	Image       *Image      `xml:"image,omitempty"`
+	Language    string      `xml:"language,omitempty"`
+	LastBuild   *Timestamp  `xml:"lastBuildDate,omitempty"`
+	Link        string      `xml:"link,omitempty"`
+	Medium      string      `xml:"https://podcastindex.org/namespace/1.0 medium,omitempty"`
+	Owner       *Owner      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd owner,omitempty"`
+	PodcastGUID string      `xml:"https://podcastindex.org/namespace/1.0 guid,omitempty"`
+	PubString   string      `xml:"pubDate,omitempty"`
+	Subtitle    string      `xml:"subtitle,omitempty"`
+	Summary     string      `xml:"summary,omitempty"`
+	Title       string      `xml:"title,omitempty"`
+	TTL         int         `xml:"ttl,omitempty"`
+}
+
+// UnmarshalXML decodes the channel metadata, taking the author from the same
+// elements as Channel does.
+func (info *FeedInfo) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type feedInfo FeedInfo
+	aux := struct {
+		*feedInfo
+		ITunesAuthor string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		RSSAuthor    string `xml:"author"`
+		Creator      string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	}{feedInfo: (*feedInfo)(info)}
+	err := dec.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+	info.Author = firstNonEmpty(aux.RSSAuthor, aux.ITunesAuthor, aux.Creator)
+	return nil
+}
+
+// ParseInfo reads the channel metadata from an RSS feed. It stops reading at
+// the first <item> element, so it's much cheaper than decoding the whole feed
+// when only the metadata is needed. Any metadata elements which come after the
+// items are ignored.
+func ParseInfo(r io.Reader) (*FeedInfo, error) {
+	d := newNamespaceDecoder(r)
+	start, err := findChannel(d)
+	if err != nil {
+		return nil, err
+	}
+	var info FeedInfo
+	td := xml.NewTokenDecoder(&headerTokens{d: d, start: start})
+	err = td.Decode(&info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// findChannel reads tokens until it finds the <channel> element within the
+// <rss> root element.
+func findChannel(d *xml.Decoder) (xml.StartElement, error) {
+	inRSS := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, fmt.Errorf("no channel element found")
+		}
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case !inRSS && start.Name.Local != "rss":
+			return start, fmt.Errorf("expected rss element, found %s", start.Name.Local)
+		case !inRSS:
+			inRSS = true
+		case start.Name.Local == "channel":
+			return start, nil
+		default:
+			if err := d.Skip(); err != nil {
+				return start, err
+			}
+		}
+	}
+}
+
+// headerTokens passes through the tokens of a <channel> element, ending the
+// element early when the first <item> is reached.
+type headerTokens struct {
+	d       *xml.Decoder
+	start   xml.StartElement
+	started bool
+	done    bool
+	depth   int
+}
+
+func (ht *headerTokens) Token() (xml.Token, error) {
+	if ht.done {
+		return nil, io.EOF
+	}
+	if !ht.started {
+		ht.started = true
+		return ht.start, nil
+	}
+	tok, err := ht.d.Token()
+	if err != nil {
+		return tok, err
+	}
+	switch t := tok.(type) {
+	case xml.StartElement:
+		if ht.depth == 0 && t.Name.Local == "item" {
+			ht.done = true
+			return ht.start.End(), nil
+		}
+		ht.depth++
+	case xml.EndElement:
+		if ht.depth == 0 {
+			ht.done = true
+		}
+		ht.depth--
+	}
+	return tok, nil
+}
@@ -0,0 +1,78 @@
+package podcast
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Integrity gives a hash of a media file, so that a download can be checked.
+// The type is sha1, sha256 or md5, with the value in hex or base64, or sri
+// for a Subresource Integrity value such as sha384-<base64>.
+type Integrity struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
+}
+
+var integrityHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// algorithm returns the name of the hash algorithm and the expected value,
+// still encoded.
+func (in *Integrity) algorithm() (string, string) {
+	typ := strings.ToLower(strings.TrimSpace(in.Type))
+	value := strings.TrimSpace(in.Value)
+	if typ == "sri" {
+		// Several hashes may be given; the first is used
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return "", ""
+		}
+		value = fields[0]
+		i := strings.Index(value, "-")
+		if i < 0 {
+			return "", ""
+		}
+		return strings.ToLower(value[:i]), value[i+1:]
+	}
+	return typ, value
+}
+
+// NewHash returns a hash of the type needed to check the integrity value, or
+// an error if the type isn't supported.
+func (in *Integrity) NewHash() (hash.Hash, error) {
+	alg, _ := in.algorithm()
+	newHash, ok := integrityHashes[alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported integrity type %s", in.Type)
+	}
+	return newHash(), nil
+}
+
+// Matches reports whether sum, as computed by the hash from NewHash, matches
+// the integrity value.
+func (in *Integrity) Matches(sum []byte) bool {
+	_, value := in.algorithm()
+	if value == "" {
+		return false
+	}
+	if want, err := hex.DecodeString(value); err == nil && bytes.Equal(want, sum) {
+		return true
+	}
+	if want, err := base64.StdEncoding.DecodeString(value); err == nil && bytes.Equal(want, sum) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"iter"
+)
+
+// ItemIter returns an iterator which decodes the items of an RSS feed one at
+// a time, so that only the current item needs to be held in memory. It's the
+// way to go through the items of a large feed, as Decode builds the whole
+// channel, with every item, before returning. The channel metadata is
+// skipped; use ParseInfo to read it. If an error occurs, it's yielded with a
+// nil item and iteration stops. The iterator reads from the decoder's input,
+// so it can only be used once.
+func (dec *Decoder) ItemIter() iter.Seq2[*Item, error] {
+	return func(yield func(*Item, error) bool) {
+		if _, err := findChannel(dec.d); err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			tok, err := dec.d.Token()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local != "item" {
+					if err := dec.d.Skip(); err != nil {
+						yield(nil, err)
+						return
+					}
+					continue
+				}
+				item := new(Item)
+				if err := dec.d.DecodeElement(item, &t); err != nil {
+					yield(nil, err)
+					return
+				}
+				if !yield(item, nil) {
+					return
+				}
+			case xml.EndElement:
+				// End of the channel
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package podcast
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDecoderItemIter(t *testing.T) {
+	f, err := os.Open(testdata("rss.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var titles []string
+	for item, err := range NewDecoder(f).ItemIter() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, item.Title)
+	}
+	rss, err := ParseFile(testdata("rss.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInt(t, "items", len(titles), len(rss.Channel.Item))
+	for i := 0; i < len(titles) && i < len(rss.Channel.Item); i++ {
+		wantString(t, "title", titles[i], rss.Channel.Item[i].Title)
+	}
+}
+
+func TestDecoderItemIterIsLazy(t *testing.T) {
+	tail := &tailReader{}
+	dec := NewDecoder(io.MultiReader(bytes.NewReader(largeFeed(1000)), tail))
+	n := 0
+	for item, err := range dec.ItemIter() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 3 {
+			wantString(t, "third title", item.Title, "Episode 998")
+			break
+		}
+	}
+	if tail.read {
+		t.Errorf("whole feed was read to get three items")
+	}
+}
@@ -0,0 +1,113 @@
+package podcast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The parts of JSON Feed documents, as defined at https://jsonfeed.org/, which
+// have equivalents in RSS.
+
+type jsonFeed struct {
+	Authors     []*jsonFeedAuthor `json:"authors"`
+	Author      *jsonFeedAuthor   `json:"author"` // Version 1.0
+	Description string            `json:"description"`
+	FeedURL     string            `json:"feed_url"`
+	HomePageURL string            `json:"home_page_url"`
+	Icon        string            `json:"icon"`
+	Items       []*jsonFeedItem   `json:"items"`
+	Language    string            `json:"language"`
+	NextURL     string            `json:"next_url"`
+	Title       string            `json:"title"`
+	Version     string            `json:"version"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	Attachments   []*jsonFeedAttachment `json:"attachments"`
+	Authors       []*jsonFeedAuthor     `json:"authors"`
+	Author        *jsonFeedAuthor       `json:"author"`
+	ContentHTML   string                `json:"content_html"`
+	ContentText   string                `json:"content_text"`
+	DatePublished string                `json:"date_published"`
+	ID            json.RawMessage       `json:"id"` // Should be a string, but some feeds use numbers
+	Summary       string                `json:"summary"`
+	Title         string                `json:"title"`
+	URL           string                `json:"url"`
+}
+
+type jsonFeedAttachment struct {
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+	MIMEType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	URL               string  `json:"url"`
+}
+
+func jsonFeedAuthors(authors []*jsonFeedAuthor, author *jsonFeedAuthor) string {
+	if author != nil {
+		authors = append(authors, author)
+	}
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func parseJSONFeed(r io.Reader) (*RSS, error) {
+	var feed jsonFeed
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(feed.Version, "https://jsonfeed.org/version/") {
+		return nil, fmt.Errorf("not a JSON Feed, version is %q", feed.Version)
+	}
+	ch := &Channel{
+		Author:      jsonFeedAuthors(feed.Authors, feed.Author),
+		Description: feed.Description,
+		Language:    feed.Language,
+		Link:        feed.HomePageURL,
+		NextPageURL: feed.NextURL,
+		Title:       feed.Title,
+	}
+	if feed.FeedURL != "" {
+		ch.AtomLink = append(ch.AtomLink, &AtomLink{Href: feed.FeedURL, Rel: "self"})
+	}
+	if feed.Icon != "" {
+		ch.Image = &Image{URL: feed.Icon, Title: feed.Title, Link: feed.HomePageURL}
+	}
+	for _, fi := range feed.Items {
+		item := &Item{
+			Author:         jsonFeedAuthors(fi.Authors, fi.Author),
+			Description:    firstNonEmpty(fi.Summary, fi.ContentHTML, fi.ContentText),
+			ContentEncoded: fi.ContentHTML,
+			Link:           fi.URL,
+			Title:          fi.Title,
+		}
+		if t, err := time.Parse(time.RFC3339, fi.DatePublished); err == nil {
+			item.PubDate = Timestamp{t}
+		}
+		var id string
+		if err := json.Unmarshal(fi.ID, &id); err != nil {
+			id = strings.TrimSpace(string(fi.ID))
+		}
+		if id != "" {
+			item.Guid = &Guid{AttrIsPermaLink: "false", Text: id}
+		}
+		if len(fi.Attachments) > 0 {
+			att := fi.Attachments[0]
+			item.Enclosure = &Enclosure{Length: att.SizeInBytes, MIMEType: att.MIMEType, URL: att.URL}
+			item.Duration = Duration(time.Duration(att.DurationInSeconds * float64(time.Second)))
+		}
+		ch.Item = append(ch.Item, item)
+	}
+	return &RSS{AttrVersion: "2.0", Channel: ch}, nil
+}
@@ -0,0 +1,101 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// Elements from the Media RSS namespace, see
+// https://www.rssboard.org/media-rss
+
+const NamespaceMedia = "http://search.yahoo.com/mrss/"
+
+// MediaContent describes a media object attached to an item, often as well as
+// or instead of an enclosure. Duration is in seconds.
+type MediaContent struct {
+	URL         string  `xml:"url,attr"`
+	Type        string  `xml:"type,attr,omitempty"`
+	Medium      string  `xml:"medium,attr,omitempty"`
+	FileSize    int64   `xml:"fileSize,attr,omitempty"`
+	Duration    float64 `xml:"duration,attr,omitempty"`
+	Height      int     `xml:"height,attr,omitempty"`
+	Width       int     `xml:"width,attr,omitempty"`
+	Title       string  `xml:"title,omitempty"`
+	Description string  `xml:"description,omitempty"`
+}
+
+// IsAudioVideo reports whether the media object is audio or video, based on
+// its medium or MIME type.
+func (mc *MediaContent) IsAudioVideo() bool {
+	switch mc.Medium {
+	case "audio", "video":
+		return true
+	}
+	return strings.HasPrefix(mc.Type, "audio/") || strings.HasPrefix(mc.Type, "video/")
+}
+
+// MediaEnclosure returns an enclosure describing the item's first audio or
+// video media:content element, or nil if it has none.
+func (item *Item) MediaEnclosure() *Enclosure {
+	for _, mc := range item.MediaContent {
+		if mc.IsAudioVideo() && mc.URL != "" {
+			return &Enclosure{Length: mc.FileSize, MIMEType: mc.Type, URL: mc.URL}
+		}
+	}
+	return nil
+}
+
+type mediaGroup struct {
+	Content []*MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// UnmarshalXML decodes the item, adding any media:content elements inside
+// media:group elements to MediaContent. The author is taken from <author>, or
+// failing that <itunes:author> or <dc:creator>. Elements which aren't
+// otherwise decoded are collected in Extensions.
+func (item *Item) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type plainItem Item
+	aux := struct {
+		*plainItem
+		MediaGroup   []*mediaGroup      `xml:"http://search.yahoo.com/mrss/ group"`
+		ITunesAuthor string             `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		RSSAuthor    string             `xml:"author"`
+		Creator      string             `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Unknown      []extensionElement `xml:",any"`
+	}{plainItem: (*plainItem)(item)}
+	err := dec.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+	item.Author = firstNonEmpty(aux.RSSAuthor, aux.ITunesAuthor, aux.Creator)
+	item.Extensions = newExtensions(aux.Unknown)
+	for _, group := range aux.MediaGroup {
+		item.MediaContent = append(item.MediaContent, group.Content...)
+	}
+	return nil
+}
+
+func (e *Enclosure) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	type enclosureXML struct {
+		Integrity *Integrity `xml:"podcast:integrity,omitempty"`
+		Length    int64      `xml:"length,attr"`
+		MIMEType  string     `xml:"type,attr"`
+		URL       string     `xml:"url,attr"`
+	}
+	return enc.EncodeElement(enclosureXML(*e), start)
+}
+
+func (mc *MediaContent) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	type mediaContentXML struct {
+		URL         string  `xml:"url,attr"`
+		Type        string  `xml:"type,attr,omitempty"`
+		Medium      string  `xml:"medium,attr,omitempty"`
+		FileSize    int64   `xml:"fileSize,attr,omitempty"`
+		Duration    float64 `xml:"duration,attr,omitempty"`
+		Height      int     `xml:"height,attr,omitempty"`
+		Width       int     `xml:"width,attr,omitempty"`
+		Title       string  `xml:"media:title,omitempty"`
+		Description string  `xml:"media:description,omitempty"`
+	}
+	return enc.EncodeElement(mediaContentXML(*mc), start)
+}
@@ -0,0 +1,76 @@
+package podcast
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Options configures ParseURL.
+type Options struct {
+	Client *http.Client // If nil, http.DefaultClient is used
+}
+
+// ParseFile reads an RSS feed from a file.
+func ParseFile(path string) (*RSS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewDecoder(f).Decode()
+}
+
+// ParseResult is a feed fetched by ParseURL, along with information from the
+// HTTP response.
+type ParseResult struct {
+	Feed         *RSS
+	FinalURL     string    // URL the feed was fetched from, after any redirects
+	ETag         string    // For conditional requests, if the server sent one
+	LastModified time.Time // Zero if the server didn't say
+}
+
+// ParseURL fetches and reads an RSS feed. The options may be nil. An error is
+// returned if the server responds with an HTTP error, or with an HTML page
+// rather than a feed. If the feed has moved, FinalURL in the result gives its
+// new location.
+func ParseURL(ctx context.Context, rawurl string, opts *Options) (*ParseResult, error) {
+	client := http.DefaultClient
+	if opts != nil && opts.Client != nil {
+		client = opts.Client
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.1")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("server returned HTTP %d for %s", resp.StatusCode, rawurl)
+	}
+	if mediatype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediatype == "text/html" {
+		return nil, fmt.Errorf("%s is a web page, not a feed", rawurl)
+	}
+	feed, err := NewDecoder(resp.Body).Decode()
+	if err != nil {
+		return nil, err
+	}
+	result := &ParseResult{
+		Feed:     feed,
+		FinalURL: resp.Request.URL.String(),
+		ETag:     resp.Header.Get("ETag"),
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		if t, err := http.ParseTime(lm); err == nil {
+			result.LastModified = t
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package podcast
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parser reads a feed from an input stream.
+type Parser interface {
+	Parse(r io.Reader) (*RSS, error)
+}
+
+// ParserFunc allows an ordinary function to be used as a Parser.
+type ParserFunc func(r io.Reader) (*RSS, error)
+
+func (f ParserFunc) Parse(r io.Reader) (*RSS, error) {
+	return f(r)
+}
+
+// formatParser is a Parser for a format which can be recognized from the
+// start of the document.
+type formatParser struct {
+	parse func(r io.Reader) (*RSS, error)
+	root  string // Local name of the root element, or { for JSON
+}
+
+func (fp formatParser) Parse(r io.Reader) (*RSS, error) {
+	return fp.parse(r)
+}
+
+// RSSParser reads RSS 2.0 feeds.
+var RSSParser Parser = formatParser{root: "rss", parse: func(r io.Reader) (*RSS, error) {
+	return NewDecoder(r).Decode()
+}}
+
+// AtomParser reads Atom feeds, converting them to RSS.
+var AtomParser Parser = formatParser{root: "feed", parse: parseAtom}
+
+// JSONFeedParser reads JSON Feed documents, converting them to RSS.
+var JSONFeedParser Parser = formatParser{root: "{", parse: parseJSONFeed}
+
+// DefaultParser reads RSS, Atom or JSON Feed documents.
+var DefaultParser = NewMultiParser(RSSParser, AtomParser, JSONFeedParser)
+
+// MultiParser chooses between several parsers. If the format of the input
+// can be recognized from its start, and one of the parsers is RSSParser,
+// AtomParser or JSONFeedParser for that format, the input is streamed to that
+// parser alone. Otherwise, the parsers are tried in turn and the result from
+// the first which succeeds is returned; as each of them needs to read the
+// input from the start, the whole input is read into memory first.
+type MultiParser struct {
+	parsers []Parser
+}
+
+// NewMultiParser returns a MultiParser which tries the given parsers in order.
+func NewMultiParser(parsers ...Parser) *MultiParser {
+	return &MultiParser{parsers: parsers}
+}
+
+// How much of the input is examined to recognize its format
+const sniffLen = 1024
+
+// Parse reads the input with the parser for its format, or failing that
+// tries each parser on it. If they all fail, the returned error describes
+// each failure.
+func (mp *MultiParser) Parse(r io.Reader) (*RSS, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	if root := documentRoot(head); root != "" {
+		for _, p := range mp.parsers {
+			if fp, ok := p.(formatParser); ok && fp.root == root {
+				return fp.Parse(br)
+			}
+		}
+	}
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []string
+	for _, p := range mp.parsers {
+		feed, err := p.Parse(bytes.NewReader(data))
+		if err == nil {
+			return feed, nil
+		}
+		msgs = append(msgs, err.Error())
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no parsers configured")
+	}
+	return nil, fmt.Errorf("unrecognized feed format: %s", strings.Join(msgs, "; "))
+}
+
+// documentRoot returns the local name of the root element of the XML
+// document which starts with head, or { if it looks like JSON. A byte order
+// mark, whitespace, processing instructions such as the XML declaration,
+// comments and a DOCTYPE are skipped. It returns an empty string if the
+// root can't be found in head.
+func documentRoot(head []byte) string {
+	s := strings.TrimPrefix(string(head), "\uFEFF")
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		switch {
+		case strings.HasPrefix(s, "{"):
+			return "{"
+		case strings.HasPrefix(s, "<?"):
+			s = skipPast(s, "?>")
+		case strings.HasPrefix(s, "<!--"):
+			s = skipPast(s, "-->")
+		case strings.HasPrefix(s, "<!"):
+			s = skipPast(s, ">")
+		case strings.HasPrefix(s, "<"):
+			end := strings.IndexAny(s, " \t\r\n/>")
+			if end < 0 {
+				return ""
+			}
+			name := s[1:end]
+			if i := strings.Index(name, ":"); i >= 0 {
+				name = name[i+1:]
+			}
+			return name
+		default:
+			return ""
+		}
+	}
+}
+
+// skipPast returns what follows the first occurrence of end in s, or an
+// empty string if there isn't one.
+func skipPast(s string, end string) string {
+	i := strings.Index(s, end)
+	if i < 0 {
+		return ""
+	}
+	return s[i+len(end):]
+}
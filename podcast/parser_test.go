@@ -0,0 +1,92 @@
+package podcast
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDocumentRoot(t *testing.T) {
+	tests := []struct {
+		head string
+		want string
+	}{
+		{`<rss version="2.0">`, "rss"},
+		{`<?xml version="1.0" encoding="UTF-8"?>` + "\n<rss>", "rss"},
+		{"\uFEFF<?xml version=\"1.0\"?><rss>", "rss"},
+		{`<?xml version="1.0"?><?xml-stylesheet href="feed.xsl"?><!-- generated --><rss>`, "rss"},
+		{`<!DOCTYPE rss PUBLIC "-//Netscape Communications//DTD RSS 0.91//EN" "http://my.netscape.com/publish/formats/rss-0.91.dtd"><rss>`, "rss"},
+		{`<feed xmlns="http://www.w3.org/2005/Atom">`, "feed"},
+		{`<atom:feed xmlns:atom="http://www.w3.org/2005/Atom">`, "feed"},
+		{"  \n{\"version\": \"https://jsonfeed.org/version/1.1\"}", "{"},
+		{`<!-- a comment which doesn't end`, ""},
+		{`<rs`, ""},
+		{`<html><head>`, "html"},
+		{`not a feed`, ""},
+		{``, ""},
+	}
+	for _, tt := range tests {
+		if got := documentRoot([]byte(tt.head)); got != tt.want {
+			t.Errorf("documentRoot(%q) = %q, want %q", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultParser(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"RSS", `<?xml version="1.0"?><rss version="2.0"><channel><title>Feed</title></channel></rss>`},
+		{"Atom", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Feed</title></feed>`},
+		{"JSON Feed", `{"version": "https://jsonfeed.org/version/1.1", "title": "Feed", "items": []}`},
+		// The root is too far in to be found, so each parser is tried
+		{"long prolog", "<!--" + strings.Repeat(" ", sniffLen) + `--><rss version="2.0"><channel><title>Feed</title></channel></rss>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rss, err := DefaultParser.Parse(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantString(t, "title", rss.Channel.Title, "Feed")
+		})
+	}
+}
+
+func TestDefaultParserErrors(t *testing.T) {
+	// Once the format is recognized, only that parser is used
+	_, err := DefaultParser.Parse(strings.NewReader(`<rss><channel><title>Broken</channel></rss>`))
+	if err == nil || strings.Contains(err.Error(), "unrecognized feed format") {
+		t.Errorf("got error %v, want the RSS parser's error", err)
+	}
+	_, err = DefaultParser.Parse(strings.NewReader(`<html><body>Not found</body></html>`))
+	if err == nil || !strings.Contains(err.Error(), "unrecognized feed format") {
+		t.Errorf("got error %v, want unrecognized feed format", err)
+	}
+}
+
+// tailReader fails the read, and records that it was asked for data.
+type tailReader struct {
+	read bool
+}
+
+func (tr *tailReader) Read(p []byte) (int, error) {
+	tr.read = true
+	return 0, errors.New("read past the end of the feed")
+}
+
+func TestDefaultParserStreams(t *testing.T) {
+	feed := largeFeed(1000)
+	tail := &tailReader{}
+	rss, err := DefaultParser.Parse(io.MultiReader(bytes.NewReader(feed), tail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInt(t, "items", len(rss.Channel.Item), 1000)
+	if tail.read {
+		t.Errorf("input was read past the end of the rss element")
+	}
+}
@@ -0,0 +1,127 @@
+package podcast
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Names which Windows reserves for devices, with or without an extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizePathComponent makes s safe to use as a single file or directory
+// name on Linux, macOS and Windows. Path separators, control characters and
+// the characters Windows forbids are replaced with underscores, trailing dots
+// and spaces are removed, and Windows device names are prefixed with an
+// underscore.
+func SanitizePathComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimRight(strings.TrimSpace(s), ". ")
+	if s == "" {
+		return "_"
+	}
+	base := strings.ToUpper(strings.SplitN(s, ".", 2)[0])
+	if reservedNames[base] {
+		s = "_" + s
+	}
+	return s
+}
+
+// TruncatePathComponent shortens a file or directory name to at most max
+// bytes, without splitting a UTF-8 character. The extension is kept if
+// there's room for it.
+func TruncatePathComponent(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	ext := path.Ext(s)
+	if len(ext) >= max {
+		ext = ""
+	}
+	stem := s[:len(s)-len(ext)]
+	cut := max - len(ext)
+	for cut > 0 && !utf8.RuneStart(stem[cut]) {
+		cut--
+	}
+	return strings.TrimRight(stem[:cut], ". ") + ext
+}
+
+// Extensions for common podcast media types, used when the enclosure URL
+// doesn't have one. The system MIME database is consulted for other types.
+var mediaExts = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/x-m4a":     ".m4a",
+	"audio/aac":       ".aac",
+	"audio/ogg":       ".ogg",
+	"audio/opus":      ".opus",
+	"audio/flac":      ".flac",
+	"audio/wav":       ".wav",
+	"audio/x-wav":     ".wav",
+	"video/mp4":       ".mp4",
+	"video/x-m4v":     ".m4v",
+	"video/quicktime": ".mov",
+	"video/webm":      ".webm",
+}
+
+// Extension returns the file extension of the enclosure, including the dot.
+// It's taken from the URL path if that has one, or otherwise guessed from the
+// MIME type. It returns an empty string if neither gives an extension.
+func (e *Enclosure) Extension() string {
+	if u, err := url.Parse(e.URL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && ext != "." {
+			return ext
+		}
+	}
+	mediatype, _, err := mime.ParseMediaType(e.MIMEType)
+	if err != nil {
+		return ""
+	}
+	if ext, ok := mediaExts[mediatype]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediatype); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// Filename returns a filename for the enclosure, taken from the last element
+// of the URL path. If the path doesn't give a usable name, or gives the
+// default.mp3 used by tracking services such as Podtrac, it returns "episode"
+// plus the extension. A name without an extension gets one from the MIME
+// type. The name is made safe with SanitizePathComponent.
+func (e *Enclosure) Filename() (string, error) {
+	u, err := url.Parse(strings.TrimSpace(e.URL))
+	if err != nil {
+		return "", fmt.Errorf("can't parse enclosure URL %s: %w", e.URL, err)
+	}
+	base := path.Base(u.Path)
+	switch base {
+	case "", ".", "/", "default.mp3":
+		return fmt.Sprintf("episode%s", e.Extension()), nil
+	}
+	if path.Ext(base) == "" {
+		base += e.Extension()
+	}
+	return SanitizePathComponent(base), nil
+}
@@ -3,72 +3,289 @@ package podcast
 import (
 	"encoding/xml"
 	"fmt"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type RSS struct {
-	AttrXmlnsItunes string   `xml:"xmlns itunes,attr"`
-	AttrVersion     string   `xml:"version,attr"`
-	Channel         *Channel `xml:"channel,omitempty"`
+	AttrXmlnsItunes string            `xml:"xmlns itunes,attr"`
+	AttrVersion     string            `xml:"version,attr"`
+	Channel         *Channel          `xml:"channel,omitempty"`
+	Namespaces      map[string]string `xml:"-"` // URIs declared on the root element by prefix
 }
 
+// UnmarshalXML decodes the feed, recording the namespaces declared on the
+// <rss> element in Namespaces. A default namespace has an empty prefix.
+func (r *RSS) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type rss RSS
+	err := dec.DecodeElement((*rss)(r), &start)
+	if err != nil {
+		return err
+	}
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			r.setNamespace(attr.Name.Local, attr.Value)
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			r.setNamespace("", attr.Value)
+		}
+	}
+	return nil
+}
+
+func (r *RSS) setNamespace(prefix string, uri string) {
+	if r.Namespaces == nil {
+		r.Namespaces = make(map[string]string)
+	}
+	r.Namespaces[prefix] = uri
+}
+
+// Image holds both the iTunes <itunes:image href="..."/> form and the RSS
+// 2.0 <image><url>...</url></image> form of a channel image. If a feed has
+// both, they're decoded into the same Image, with AttrHref set from the
+// iTunes form and URL from the RSS form.
 type Image struct {
 	AttrHref string   `xml:"href,attr"`
+	Link     string   `xml:"link,omitempty"`
+	Title    string   `xml:"title,omitempty"`
+	URL      string   `xml:"url,omitempty"`
 	XMLName  xml.Name `xml:"image,omitempty"`
 }
 
+// BestURL returns the image URL, from whichever form of image element was
+// used. If both were, the iTunes form is preferred, as it's usually the
+// larger image. It returns an empty string for a nil Image.
+func (img *Image) BestURL() string {
+	if img == nil {
+		return ""
+	}
+	if img.AttrHref != "" {
+		return img.AttrHref
+	}
+	return img.URL
+}
+
+// Href returns the image URL, from whichever form of image element was used.
+//
+// Deprecated: Use BestURL, which also handles a nil Image.
+func (img *Image) Href() string {
+	return img.BestURL()
+}
+
 type Category struct {
-	AttrText string   `xml:"text,attr"`
-	XMLName  xml.Name `xml:"category,omitempty"`
+	AttrText    string    `xml:"text,attr"`
+	Subcategory *Category `xml:"category,omitempty"` // iTunes allows one level of nesting
+	XMLName     xml.Name  `xml:"category,omitempty"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// Cloud describes a service supporting the rssCloud interface, which can be
+// used to be notified of updates to the channel.
+type Cloud struct {
+	Domain            string `xml:"domain,attr"`
+	Port              int    `xml:"port,attr"`
+	Path              string `xml:"path,attr"`
+	RegisterProcedure string `xml:"registerProcedure,attr"`
+	Protocol          string `xml:"protocol,attr"`
 }
 
 type Channel struct {
-	Author      string      `xml:"author,omitempty"`
-	Category    []*Category `xml:"category,omitempty"`
-	Copyright   string      `xml:"copyright,omitempty"`
-	Description string      `xml:"description,omitempty"`
-	Explicit    string      `xml:"explicit,omitempty"`
-	Image       *Image      `xml:"image,omitempty"`
-	Item        []*Item     `xml:"item,omitempty"`
-	Language    string      `xml:"language,omitempty"`
-	LastBuild   *Timestamp  `xml:"lastBuildDate,omitempty"`
-	Link        string      `xml:"link,omitempty"`
-	Owner       *Owner      `xml:"owner,omitempty"`
-	PubString   string      `xml:"pubDate,omitempty"` // TODO: Parse
-	Subtitle    string      `xml:"subtitle,omitempty"`
-	Summary     string      `xml:"summary,omitempty"`
-	Title       string      `xml:"title,omitempty"`
+	AtomLink        []*AtomLink `xml:"http://www.w3.org/2005/Atom link,omitempty"`
+	Author          string      `xml:"author,omitempty"`
+	Category        []*Category `xml:"category,omitempty"`
+	Cloud           *Cloud      `xml:"cloud,omitempty"`
+	Copyright       string      `xml:"copyright,omitempty"`
+	Description     string      `xml:"description,omitempty"`
+	Explicit        string      `xml:"explicit,omitempty"`
+	Extensions      Extensions  `xml:"-"` // Elements not otherwise decoded
+	Funding         []*Funding  `xml:"https://podcastindex.org/namespace/1.0 funding,omitempty"`
+	Image           *Image      `xml:"image,omitempty"`
+	Item            []*Item     `xml:"item,omitempty"`
+	Language        string      `xml:"language,omitempty"`
+	LastBuild       *Timestamp  `xml:"lastBuildDate,omitempty"`
+	Link            string      `xml:"link,omitempty"`
+	Locked          *Locked     `xml:"https://podcastindex.org/namespace/1.0 locked,omitempty"`
+	ManagingEditor  string      `xml:"managingEditor,omitempty"`
+	Medium          string      `xml:"https://podcastindex.org/namespace/1.0 medium,omitempty"`
+	NextPageURL     string      `xml:"-"` // From <atom:link rel="next">, see RFC 5005
+	Owner           *Owner      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd owner,omitempty"`
+	Persons         []*Person   `xml:"https://podcastindex.org/namespace/1.0 person,omitempty"`
+	PodcastGUID     string      `xml:"https://podcastindex.org/namespace/1.0 guid,omitempty"` // Stays the same if the feed moves
+	PubString       string      `xml:"pubDate,omitempty"`                                     // TODO: Parse
+	SkipDays        []string    `xml:"skipDays>day,omitempty"`
+	SkipHours       []int       `xml:"skipHours>hour,omitempty"`
+	Subtitle        string      `xml:"subtitle,omitempty"`
+	Summary         string      `xml:"summary,omitempty"`
+	Title           string      `xml:"title,omitempty"`
+	TTL             int         `xml:"ttl,omitempty"` // Minutes the feed may be cached
+	UpdateFrequency int         `xml:"updateFrequency,omitempty"`
+	UpdatePeriod    string      `xml:"updatePeriod,omitempty"`
+	WebMaster       string      `xml:"webMaster,omitempty"`
+}
+
+// Extensions holds the text of elements the package doesn't otherwise
+// decode, such as proprietary extensions, by namespace URI and local name.
+// There's an entry in the slice for each occurrence of the element.
+type Extensions map[xml.Name][]string
+
+// extensionElement is used to capture unknown elements for Extensions.
+type extensionElement struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+func newExtensions(elements []extensionElement) Extensions {
+	if len(elements) == 0 {
+		return nil
+	}
+	ext := make(Extensions)
+	for _, el := range elements {
+		ext[el.XMLName] = append(ext[el.XMLName], strings.TrimSpace(el.Text))
+	}
+	return ext
+}
+
+// Get returns the text of the first element with the given namespace URI
+// and local name, or an empty string if there isn't one.
+func (ext Extensions) Get(space string, local string) string {
+	if values := ext[xml.Name{Space: space, Local: local}]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// UnmarshalXML decodes the channel. The author is taken from <author>, or
+// failing that <itunes:author> or Dublin Core's <dc:creator>. Elements which
+// aren't otherwise decoded are collected in Extensions.
+func (ch *Channel) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type channel Channel
+	aux := struct {
+		*channel
+		ITunesAuthor string             `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		RSSAuthor    string             `xml:"author"`
+		Creator      string             `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		Unknown      []extensionElement `xml:",any"`
+	}{channel: (*channel)(ch)}
+	err := dec.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+	ch.Author = firstNonEmpty(aux.RSSAuthor, aux.ITunesAuthor, aux.Creator)
+	ch.Extensions = newExtensions(aux.Unknown)
+	for _, link := range ch.AtomLink {
+		if link.Rel == "next" {
+			ch.NextPageURL = link.Href
+		}
+	}
+	return nil
+}
+
+// firstNonEmpty returns the first of the strings which isn't blank.
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if strings.TrimSpace(s) != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// NewChannel returns a channel with the fields required by RSS 2.0 set, and
+// the language defaulted to US English.
+func NewChannel(title, link, description string) *Channel {
+	return &Channel{
+		Title:       title,
+		Link:        link,
+		Description: description,
+		Language:    "en-us",
+	}
+}
+
+// AddItem appends an item to the channel, returning the channel so that calls
+// can be chained.
+func (ch *Channel) AddItem(item *Item) *Channel {
+	ch.Item = append(ch.Item, item)
+	return ch
 }
 
 type Enclosure struct {
-	Length   int    `xml:"length,attr"`
-	MIMEType string `xml:"type,attr"`
-	URL      string `xml:"url,attr"`
+	Integrity *Integrity `xml:"https://podcastindex.org/namespace/1.0 integrity,omitempty"` // From a podcast:integrity child element
+	Length    int64      `xml:"length,attr"`                                                // In bytes; int64 so files over 2GB work on 32-bit systems
+	MIMEType  string     `xml:"type,attr"`
+	URL       string     `xml:"url,attr"`
 }
 
 type Guid struct {
-	AttrIsPermaLink string `xml:"isPermaLink,attr"`
+	AttrIsPermaLink string `xml:"isPermaLink,attr,omitempty"`
 	Text            string `xml:",chardata"`
 }
 
+// IsPermaLink reports whether the GUID is a URL for the item. Per the RSS
+// spec, that's the case unless isPermaLink is explicitly false.
+func (g *Guid) IsPermaLink() bool {
+	return !strings.EqualFold(strings.TrimSpace(g.AttrIsPermaLink), "false")
+}
+
+// AsURL parses the GUID as a URL, if it's a permalink.
+func (g *Guid) AsURL() (*url.URL, error) {
+	if !g.IsPermaLink() {
+		return nil, fmt.Errorf("guid %s is not a permalink", g.Text)
+	}
+	return url.Parse(strings.TrimSpace(g.Text))
+}
+
+// Source identifies the feed an item was originally published in.
+type Source struct {
+	Title string `xml:",chardata"`
+	URL   string `xml:"url,attr"`
+}
+
 type Item struct {
-	Author      string     `xml:"author,omitempty"`
-	Category    string     `xml:"category,omitempty"`
-	Description string     `xml:"description,omitempty"`
-	Duration    Duration   `xml:"duration,omitempty"`
-	Enclosure   *Enclosure `xml:"enclosure,omitempty"`
-	Guid        *Guid      `xml:"guid,omitempty"`
-	Keywords    Keywords   `xml:"keywords,omitempty"` // TODO: Parse
-	PubDate     Timestamp  `xml:"pubDate,omitempty"`
-	Title       string     `xml:"title,omitempty"`
+	AlternateEnclosures []*AlternateEnclosure `xml:"https://podcastindex.org/namespace/1.0 alternateEnclosure,omitempty"`
+	Author              string                `xml:"author,omitempty"`
+	Category            string                `xml:"category,omitempty"`
+	Chapters            *Chapters             `xml:"https://podcastindex.org/namespace/1.0 chapters,omitempty"`
+	Comments            string                `xml:"comments,omitempty"`
+	ContentEncoded      string                `xml:"http://purl.org/rss/1.0/modules/content/ encoded,omitempty"` // Full HTML show notes
+	Description         string                `xml:"description,omitempty"`
+	Duration            Duration              `xml:"duration,omitempty"`
+	Enclosure           *Enclosure            `xml:"enclosure,omitempty"`
+	Episode             int                   `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode,omitempty"`
+	Explicit            string                `xml:"explicit,omitempty"`
+	Extensions          Extensions            `xml:"-"` // Elements not otherwise decoded
+	Guid                *Guid                 `xml:"guid,omitempty"`
+	Keywords            Keywords              `xml:"keywords,omitempty"` // TODO: Parse
+	Link                string                `xml:"link,omitempty"`
+	MediaContent        []*MediaContent       `xml:"http://search.yahoo.com/mrss/ content,omitempty"`
+	Persons             []*Person             `xml:"https://podcastindex.org/namespace/1.0 person,omitempty"`
+	PubDate             Timestamp             `xml:"pubDate,omitempty"`
+	Season              int                   `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season,omitempty"`
+	Soundbites          []*Soundbite          `xml:"https://podcastindex.org/namespace/1.0 soundbite,omitempty"`
+	Source              *Source               `xml:"source,omitempty"`
+	Title               string                `xml:"title,omitempty"`
+	Transcripts         []*Transcript         `xml:"https://podcastindex.org/namespace/1.0 transcript,omitempty"`
+}
+
+// NewItem returns an item with the given title and publication date.
+func NewItem(title string, pubDate time.Time) *Item {
+	return &Item{
+		Title:   title,
+		PubDate: Timestamp{pubDate},
+	}
 }
 
 type Owner struct {
 	Email   string   `xml:"email,omitempty"`
 	Name    string   `xml:"name,omitempty"`
-	XMLName xml.Name `xml:"owner,omitempty"`
+	XMLName xml.Name `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd owner,omitempty"`
 }
 
 // Keyword unmarshaling
@@ -89,18 +306,49 @@ func (kw *Keywords) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error
 	return err
 }
 
+func (kw *Keywords) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	return enc.EncodeElement(strings.Join(*kw, ", "), start)
+}
+
 // Custom Timestamp unmarshaling
 
 type Timestamp struct {
 	time.Time
 }
 
+// Offsets of the time zone names allowed by RFC 822. Go's time.Parse accepts
+// zone names, but gives them a zero offset unless they're the local zone.
+var rfc822Zones = map[string]string{
+	"UT":  "+0000",
+	"GMT": "+0000",
+	"Z":   "+0000",
+	"EST": "-0500",
+	"EDT": "-0400",
+	"CST": "-0600",
+	"CDT": "-0500",
+	"MST": "-0700",
+	"MDT": "-0600",
+	"PST": "-0800",
+	"PDT": "-0700",
+}
+
+// UnmarshalXML parses an RFC 1123 date with a numeric time zone, or one of
+// the zone names RFC 822 allows, such as EDT.
 func (ts *Timestamp) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
 	var content string
 	err := dec.DecodeElement(&content, &start)
 	if err != nil {
 		return err
 	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil
+	}
+	if i := strings.LastIndex(content, " "); i >= 0 {
+		if offset, ok := rfc822Zones[strings.ToUpper(content[i+1:])]; ok {
+			content = content[:i+1] + offset
+		}
+	}
 	t, err := time.Parse(time.RFC1123Z, content)
 	if err == nil {
 		*ts = Timestamp{t}
@@ -112,15 +360,59 @@ func (ts *Timestamp) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) erro
 
 type Duration time.Duration
 
+// String formats the duration the way podcast players do, as H:MM:SS, or
+// MM:SS if it's less than an hour.
 func (dur *Duration) String() string {
+	secs := int64(time.Duration(*dur).Round(time.Second) / time.Second)
+	h := secs / 3600
+	m := secs / 60 % 60
+	s := secs % 60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
+
+// GoString formats the duration in Go's style, such as 1h2m3s.
+func (dur *Duration) GoString() string {
 	return time.Duration(*dur).String()
 }
 
 var babylon = []int{1, 60, 3600, 86400}
 
-func parseDuration(ds string) (time.Duration, error) {
+// ISO 8601 durations with weeks, days, hours, minutes and seconds. Years and
+// months aren't accepted, as their length varies.
+var iso8601Duration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// ParseDuration parses a duration in the H:MM:SS format used by iTunes, where
+// the hours and minutes are optional, so a bare number is a number of seconds.
+// A leading days field is also accepted, as D:HH:MM:SS. The seconds may have a
+// decimal fraction, as in 2:30.5. ISO 8601 durations such as PT1H30M are also
+// accepted. An empty string is treated as a zero duration, as some feeds
+// include an empty duration element.
+func ParseDuration(ds string) (time.Duration, error) {
+	ds = strings.TrimSpace(ds)
+	if ds == "" {
+		return 0, nil
+	}
+	if strings.HasPrefix(ds, "P") {
+		return parseISO8601Duration(ds)
+	}
 	chunks := strings.Split(ds, ":")
 	lc := len(chunks)
+	if lc > len(babylon) {
+		return time.Duration(0), fmt.Errorf("can't parse %s as duration, too many fields", ds)
+	}
+	var frac time.Duration
+	if last := chunks[lc-1]; strings.Contains(last, ".") {
+		dot := strings.Index(last, ".")
+		f, err := strconv.ParseFloat("0"+last[dot:], 64)
+		if err != nil {
+			return time.Duration(0), fmt.Errorf("can't parse %s as duration, bad fraction %s: %s", ds, last[dot:], err)
+		}
+		frac = time.Duration(f*1000) * time.Millisecond
+		chunks[lc-1] = last[:dot]
+	}
 	secs := 0
 	for i := 0; i < lc; i++ {
 		j := lc - i - 1
@@ -131,7 +423,28 @@ func parseDuration(ds string) (time.Duration, error) {
 		}
 		secs += s * babylon[i]
 	}
-	return time.Duration(secs) * time.Second, nil
+	return time.Duration(secs)*time.Second + frac, nil
+}
+
+// parseISO8601Duration parses an ISO 8601 duration such as P1DT2H3M4S.
+func parseISO8601Duration(ds string) (time.Duration, error) {
+	m := iso8601Duration.FindStringSubmatch(ds)
+	if m == nil || ds == "P" || strings.HasSuffix(ds, "T") {
+		return time.Duration(0), fmt.Errorf("can't parse %s as ISO 8601 duration", ds)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.Replace(m[i+1], ",", ".", 1), 64)
+		if err != nil {
+			return time.Duration(0), fmt.Errorf("can't parse %s as ISO 8601 duration: %s", ds, err)
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d, nil
 }
 
 func (dur *Duration) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
@@ -140,7 +453,7 @@ func (dur *Duration) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) erro
 	if err != nil {
 		return err
 	}
-	d, err := parseDuration(content)
+	d, err := ParseDuration(content)
 	if err == nil {
 		*dur = Duration(d)
 	}
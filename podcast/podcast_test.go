@@ -0,0 +1,593 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testdata(name string) string {
+	return filepath.Join("..", "testdata", name)
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+		check   func(t *testing.T, rss *RSS)
+	}{
+		{
+			name: "RSS 2.0",
+			file: "standard.xml",
+			check: func(t *testing.T, rss *RSS) {
+				ch := rss.Channel
+				wantString(t, "title", ch.Title, "Plain RSS")
+				wantString(t, "language", ch.Language, "en-gb")
+				wantInt(t, "ttl", ch.TTL, 60)
+				wantInt(t, "items", len(ch.Item), 2)
+				item := ch.Item[0]
+				wantString(t, "item author", item.Author, "host@example.com (Host)")
+				wantString(t, "item category", item.Category, "Talk")
+				wantString(t, "guid", item.Guid.Text, "plain-2")
+				if item.Guid.IsPermaLink() {
+					t.Errorf("guid %s is a permalink", item.Guid.Text)
+				}
+				wantTime(t, "pubDate", item.PubDate.Time, time.Date(2017, 8, 8, 8, 0, 0, 0, time.UTC))
+				wantString(t, "enclosure", item.Enclosure.URL, "http://example.com/plain/2.mp3")
+				wantInt(t, "length", int(item.Enclosure.Length), 2048)
+				if !ch.Item[1].Guid.IsPermaLink() {
+					t.Errorf("guid %s isn't a permalink", ch.Item[1].Guid.Text)
+				}
+			},
+		},
+		{
+			name: "iTunes",
+			file: "rss.xml",
+			check: func(t *testing.T, rss *RSS) {
+				ch := rss.Channel
+				wantString(t, "author", ch.Author, "Ray Ortega, Dave Jackson and Daniel J. Lewis.")
+				wantString(t, "explicit", ch.Explicit, "clean")
+				wantString(t, "owner", ch.Owner.Name, "Ray Ortega")
+				wantString(t, "email", ch.Owner.Email, "ray@podcastersroundtable.com")
+				wantString(t, "image", ch.Image.BestURL(), "http://static.libsyn.com/p/assets/1/3/c/4/13c44c50bb4aea9f/PodcastersRoundtableITUNESimage3000.jpg")
+				wantInt(t, "categories", len(ch.Category), 3)
+				wantInt(t, "items", len(ch.Item), 100)
+				item := ch.Item[0]
+				wantDuration(t, "duration", time.Duration(item.Duration), time.Hour+11*time.Minute+47*time.Second)
+				wantInt(t, "episode", item.Episode, 99)
+				wantInt(t, "keywords", len(item.Keywords), 8)
+				wantString(t, "item author", item.Author, "Ray Ortega, Daniel J. Lewis, David Jackson, David Kadavy")
+			},
+		},
+		{
+			name: "missing optional fields",
+			file: "minimal.xml",
+			check: func(t *testing.T, rss *RSS) {
+				ch := rss.Channel
+				wantInt(t, "items", len(ch.Item), 2)
+				if ch.Owner != nil || ch.Image != nil || ch.LastBuild != nil {
+					t.Errorf("optional channel elements set: owner %v, image %v, lastBuildDate %v", ch.Owner, ch.Image, ch.LastBuild)
+				}
+				item := ch.Item[0]
+				if item.Guid != nil || !item.PubDate.IsZero() || item.Duration != 0 {
+					t.Errorf("optional item elements set: guid %v, pubDate %v, duration %v", item.Guid, item.PubDate, item.Duration.GoString())
+				}
+				wantInt(t, "length", int(item.Enclosure.Length), 0)
+				if ch.Item[1].Enclosure != nil {
+					t.Errorf("enclosure %v, want nil", ch.Item[1].Enclosure)
+				}
+			},
+		},
+		{
+			name: "empty elements",
+			file: "empty.xml",
+			check: func(t *testing.T, rss *RSS) {
+				item := rss.Channel.Item[0]
+				if !item.PubDate.IsZero() || item.Duration != 0 {
+					t.Errorf("pubDate %v, duration %v, want zero", item.PubDate, item.Duration.GoString())
+				}
+			},
+		},
+		{
+			name:    "malformed timestamp",
+			file:    "badpubdate.xml",
+			wantErr: true,
+		},
+		{
+			name: "named time zone",
+			file: "podcast.xml",
+			check: func(t *testing.T, rss *RSS) {
+				ch := rss.Channel
+				wantString(t, "title", ch.Title, "Health Update")
+				wantString(t, "author", ch.Author, "The New York Times")
+				wantTime(t, "lastBuildDate", ch.LastBuild.Time, time.Date(2008, 3, 25, 3, 30, 7, 0, time.UTC))
+				wantInt(t, "items", len(ch.Item), 4)
+				item := ch.Item[0]
+				wantTime(t, "pubDate", item.PubDate.Time, time.Date(2008, 3, 21, 13, 51, 0, 0, time.UTC))
+				if _, offset := item.PubDate.Zone(); offset != -4*60*60 {
+					t.Errorf("pubDate offset %d, want %d", offset, -4*60*60)
+				}
+				wantDuration(t, "duration", time.Duration(item.Duration), 65*time.Second)
+			},
+		},
+		{
+			name: "non-standard durations",
+			file: "durations.xml",
+			check: func(t *testing.T, rss *RSS) {
+				want := []time.Duration{
+					90 * time.Minute,
+					150 * time.Second,
+					150*time.Second + 500*time.Millisecond,
+					26*time.Hour + 3*time.Minute + 4*time.Second,
+					62 * time.Minute,
+					45 * time.Second,
+				}
+				items := rss.Channel.Item
+				wantInt(t, "items", len(items), len(want))
+				for i := 0; i < len(items) && i < len(want); i++ {
+					wantDuration(t, items[i].Title, time.Duration(items[i].Duration), want[i])
+				}
+			},
+		},
+		{
+			name: "empty feed",
+			file: "noitems.xml",
+			check: func(t *testing.T, rss *RSS) {
+				wantString(t, "title", rss.Channel.Title, "Coming Soon")
+				wantInt(t, "items", len(rss.Channel.Item), 0)
+				if rss.Channel.LatestItem() != nil {
+					t.Errorf("LatestItem returned %v, want nil", rss.Channel.LatestItem())
+				}
+			},
+		},
+		{
+			name:  "all fields",
+			file:  "full.xml",
+			check: checkFullFeed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rss, err := ParseFile(testdata(tt.file))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFile(%s) succeeded, want error", tt.file)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFile(%s): %v", tt.file, err)
+			}
+			if rss.Channel == nil {
+				t.Fatalf("ParseFile(%s): no channel", tt.file)
+			}
+			tt.check(t, rss)
+		})
+	}
+}
+
+// checkFullFeed checks the contents of testdata/full.xml.
+func checkFullFeed(t *testing.T, rss *RSS) {
+	t.Helper()
+	wantString(t, "version", rss.AttrVersion, "2.0")
+	wantString(t, "xmlns:podcast", rss.Namespaces["podcast"], NamespacePodcast)
+	ch := rss.Channel
+	wantString(t, "title", ch.Title, "Everything")
+	wantString(t, "link", ch.Link, "http://example.com/everything/")
+	wantString(t, "next page", ch.NextPageURL, "http://example.com/everything/feed.xml?page=2")
+	wantInt(t, "atom links", len(ch.AtomLink), 2)
+	wantString(t, "description", ch.Description, "A feed using every element the package supports.")
+	wantString(t, "language", ch.Language, "en-us")
+	wantString(t, "copyright", ch.Copyright, "2017 Example")
+	wantString(t, "managingEditor", ch.ManagingEditor, "editor@example.com")
+	wantString(t, "webMaster", ch.WebMaster, "webmaster@example.com")
+	wantString(t, "pubDate", ch.PubString, "Tue, 15 Aug 2017 06:56:52 +0000")
+	wantTime(t, "lastBuildDate", ch.LastBuild.Time, time.Date(2017, 8, 16, 7, 0, 0, 0, time.UTC))
+	wantString(t, "cloud", ch.Cloud.Domain, "rpc.example.com")
+	wantInt(t, "ttl", ch.TTL, 120)
+	wantDuration(t, "update interval", ch.UpdateInterval(), 12*time.Hour)
+	wantInt(t, "skipHours", len(ch.SkipHours), 2)
+	wantInt(t, "skipDays", len(ch.SkipDays), 1)
+	wantString(t, "author", ch.Author, "Jane Host")
+	wantString(t, "subtitle", ch.Subtitle, "Every element")
+	wantString(t, "summary", ch.Summary, "A summary of everything.")
+	wantString(t, "explicit", ch.Explicit, "no")
+	wantString(t, "image url", ch.Image.URL, "http://example.com/everything/small.jpg")
+	wantString(t, "image href", ch.Image.AttrHref, "http://example.com/everything/large.jpg")
+	wantString(t, "owner", ch.Owner.Name, "Jane Host")
+	wantString(t, "owner email", ch.Owner.Email, "jane@example.com")
+	wantInt(t, "categories", len(ch.Category), 2)
+	wantString(t, "category", ch.Category[0].AttrText, "Technology")
+	wantString(t, "locked", ch.Locked.Value, "yes")
+	wantString(t, "podcast:guid", ch.PodcastGUID, "917393e3-1b1e-5cef-ace4-edaa54e1f810")
+	wantString(t, "medium", ch.Medium, "podcast")
+	wantString(t, "funding", ch.Funding[0].Message, "Support the show")
+	wantInt(t, "hosts", len(ch.Hosts()), 1)
+	if len(ch.Extensions) != 0 {
+		t.Errorf("unexpected extensions %v", ch.Extensions)
+	}
+
+	wantInt(t, "items", len(ch.Item), 1)
+	item := ch.Item[0]
+	wantString(t, "item title", item.Title, "Episode One")
+	wantString(t, "item link", item.Link, "http://example.com/everything/1")
+	wantString(t, "item description", item.Description, "The first episode.")
+	wantString(t, "content:encoded", item.ContentEncoded, "<p>The <b>first</b> episode.</p>")
+	wantString(t, "item author", item.Author, "Jane Host")
+	wantString(t, "item category", item.Category, "Technology")
+	wantString(t, "comments", item.Comments, "http://example.com/everything/1#comments")
+	wantString(t, "guid", item.Guid.Text, "everything-1")
+	wantTime(t, "item pubDate", item.PubDate.Time, time.Date(2017, 8, 15, 6, 56, 52, 0, time.UTC))
+	wantString(t, "enclosure", item.Enclosure.URL, "http://example.com/everything/1.mp3")
+	wantInt(t, "length", int(item.Enclosure.Length), 34531409)
+	wantString(t, "integrity", item.Enclosure.Integrity.Type, "sha256")
+	wantString(t, "alternate enclosure", item.EnclosureOfType("audio/opus").URL, "http://example.com/everything/1.opus")
+	wantString(t, "media:content", item.MediaContent[0].URL, "http://example.com/everything/1.mp4")
+	wantString(t, "source", item.Source.Title, "Original Feed")
+	wantDuration(t, "duration", time.Duration(item.Duration), time.Hour+11*time.Minute+47*time.Second)
+	wantString(t, "item explicit", item.Explicit, "no")
+	wantInt(t, "keywords", len(item.Keywords), 3)
+	wantInt(t, "season", item.Season, 2)
+	wantInt(t, "episode", item.Episode, 1)
+	wantString(t, "transcript", item.Transcripts[0].URL, "http://example.com/everything/1.vtt")
+	wantString(t, "chapters", item.Chapters.URL, "http://example.com/everything/1.json")
+	wantString(t, "soundbite", item.Soundbites[0].Title, "The best bit")
+	wantInt(t, "guests", len(item.Guests()), 1)
+	if len(item.Extensions) != 0 {
+		t.Errorf("unexpected item extensions %v", item.Extensions)
+	}
+}
+
+func wantString(t *testing.T, what string, got string, want string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %q, want %q", what, got, want)
+	}
+}
+
+func wantInt(t *testing.T, what string, got int, want int) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %d, want %d", what, got, want)
+	}
+}
+
+func wantTime(t *testing.T, what string, got time.Time, want time.Time) {
+	t.Helper()
+	if !got.Equal(want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func wantDuration(t *testing.T, what string, got time.Duration, want time.Duration) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestKeywordsRoundTrip(t *testing.T) {
+	var item Item
+	if err := xml.Unmarshal([]byte(`<item xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><itunes:keywords>a, b ,c</itunes:keywords></item>`), &item); err != nil {
+		t.Fatal(err)
+	}
+	want := Keywords{"a", "b", "c"}
+	if !reflect.DeepEqual(item.Keywords, want) {
+		t.Fatalf("parsed keywords %q, want %q", item.Keywords, want)
+	}
+	out, err := xml.Marshal(&item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "<itunes:keywords>a, b, c</itunes:keywords>") {
+		t.Errorf("marshaled %s, want <itunes:keywords>a, b, c</itunes:keywords>", out)
+	}
+	var again Item
+	if err := xml.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Keywords, want) {
+		t.Errorf("keywords after round trip %q, want %q", again.Keywords, want)
+	}
+}
+
+func TestNestedCategories(t *testing.T) {
+	rss, err := ParseFile(testdata("categories.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		sub  string
+	}{
+		{"Society & Culture", "Documentary"},
+		{"News", ""},
+		{"Technology", "Podcasting"},
+	}
+	cats := rss.Channel.Category
+	if len(cats) != len(tests) {
+		t.Fatalf("got %d categories, want %d", len(cats), len(tests))
+	}
+	for i, tt := range tests {
+		wantString(t, "category", cats[i].AttrText, tt.text)
+		if tt.sub == "" {
+			if cats[i].Subcategory != nil {
+				t.Errorf("%s has subcategory %q, want none", tt.text, cats[i].Subcategory.AttrText)
+			}
+			continue
+		}
+		if cats[i].Subcategory == nil {
+			t.Errorf("%s has no subcategory, want %q", tt.text, tt.sub)
+			continue
+		}
+		wantString(t, tt.text+" subcategory", cats[i].Subcategory.AttrText, tt.sub)
+		if cats[i].Subcategory.Subcategory != nil {
+			t.Errorf("%s/%s has a subcategory", tt.text, tt.sub)
+		}
+	}
+}
+
+// largeFeed returns a feed with n items, in the style of rss.xml.
+func largeFeed(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+<title>Large Feed</title>
+<link>http://example.com/large/</link>
+<description>A synthetic feed for benchmarks.</description>
+<itunes:author>Bench Mark</itunes:author>
+<itunes:category text="Technology"><itunes:category text="Podcasting"/></itunes:category>
+`)
+	pubDate := time.Date(2017, 8, 15, 6, 56, 52, 0, time.UTC)
+	for i := n; i > 0; i-- {
+		fmt.Fprintf(&b, `<item>
+<title>Episode %d</title>
+<link>http://example.com/large/%d</link>
+<guid isPermaLink="false">large-%d</guid>
+<pubDate>%s</pubDate>
+<description>Show notes for episode %d.</description>
+<content:encoded><![CDATA[<p>Show notes for <b>episode %d</b>.</p>]]></content:encoded>
+<enclosure url="http://example.com/large/%d.mp3" length="34531409" type="audio/mpeg"/>
+<itunes:duration>01:11:%02d</itunes:duration>
+<itunes:episode>%d</itunes:episode>
+<itunes:keywords>one, two, three</itunes:keywords>
+<itunes:author>Bench Mark</itunes:author>
+</item>
+`, i, i, i, pubDate.Format(time.RFC1123Z), i, i, i, i%60, i)
+		pubDate = pubDate.Add(-7 * 24 * time.Hour)
+	}
+	b.WriteString("</channel>\n</rss>\n")
+	return b.Bytes()
+}
+
+func BenchmarkParseLargeFeed(b *testing.B) {
+	data := largeFeed(1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var rss RSS
+		if err := xml.Unmarshal(data, &rss); err != nil {
+			b.Fatal(err)
+		}
+		if len(rss.Channel.Item) != 1000 {
+			b.Fatalf("got %d items, want 1000", len(rss.Channel.Item))
+		}
+	}
+}
+
+func BenchmarkParseFixtures(b *testing.B) {
+	files, err := filepath.Glob(testdata("*.xml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, file := range files {
+		if unparseable[filepath.Base(file)] {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(filepath.Base(file), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseTimestamp(b *testing.B) {
+	data := []byte("<pubDate>Tue, 15 Aug 2017 06:56:52 +0000</pubDate>")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var ts Timestamp
+		if err := xml.Unmarshal(data, &ts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseDuration(b *testing.B) {
+	for _, ds := range []string{"5400", "1:11:47", "2:30.5", "PT1H11M47S"} {
+		b.Run(ds, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseDuration(ds); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDurationUnmarshalXML(t *testing.T) {
+	tests := []struct {
+		xml     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{xml: `<itunes:duration/>`},
+		{xml: `<itunes:duration></itunes:duration>`},
+		{xml: `<itunes:duration>  </itunes:duration>`},
+		{xml: "<itunes:duration>\n\t</itunes:duration>"},
+		{xml: `<itunes:duration>1:05</itunes:duration>`, want: 65 * time.Second},
+		{xml: `<itunes:duration>soon</itunes:duration>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var dur Duration
+		err := xml.Unmarshal([]byte(tt.xml), &dur)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %v, want error", tt.xml, dur.GoString())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.xml, err)
+			continue
+		}
+		wantDuration(t, tt.xml, time.Duration(dur), tt.want)
+	}
+}
+
+func TestTimestampZones(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "Tue, 15 Aug 2017 06:56:52 +0000", want: time.Date(2017, 8, 15, 6, 56, 52, 0, time.UTC)},
+		{in: "Tue, 15 Aug 2017 06:56:52 -0400", want: time.Date(2017, 8, 15, 10, 56, 52, 0, time.UTC)},
+		{in: "Tue, 15 Aug 2017 06:56:52 GMT", want: time.Date(2017, 8, 15, 6, 56, 52, 0, time.UTC)},
+		{in: "Tue, 15 Aug 2017 06:56:52 EDT", want: time.Date(2017, 8, 15, 10, 56, 52, 0, time.UTC)},
+		{in: "Tue, 15 Aug 2017 06:56:52 pst", want: time.Date(2017, 8, 15, 14, 56, 52, 0, time.UTC)},
+		{in: " Tue, 15 Aug 2017 06:56:52 UT\n", want: time.Date(2017, 8, 15, 6, 56, 52, 0, time.UTC)},
+		{in: "Tue, 15 Aug 2017 06:56:52 XYZ", wantErr: true},
+		{in: "2017-08-15T06:56:52Z", wantErr: true},
+	}
+	for _, tt := range tests {
+		var ts Timestamp
+		err := xml.Unmarshal([]byte("<pubDate>"+tt.in+"</pubDate>"), &ts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %v, want error", tt.in, ts)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		wantTime(t, tt.in, ts.Time, tt.want)
+	}
+}
+
+func TestEmptyPubDate(t *testing.T) {
+	rss, err := ParseFile(testdata("empty.xml"))
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	item := rss.Channel.Item[0]
+	if !item.PubDate.IsZero() {
+		t.Errorf("pubDate = %v, want zero", item.PubDate)
+	}
+	for _, s := range []string{`<pubDate/>`, `<pubDate> </pubDate>`} {
+		var ts Timestamp
+		if err := xml.Unmarshal([]byte(s), &ts); err != nil {
+			t.Errorf("%s: %v", s, err)
+		} else if !ts.IsZero() {
+			t.Errorf("%s: got %v, want zero", s, ts)
+		}
+	}
+}
+
+func TestITunesOwner(t *testing.T) {
+	rss, err := ParseFile(testdata("owners.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := rss.Channel.Owner
+	if owner == nil {
+		t.Fatal("no owner")
+	}
+	wantString(t, "owner name", owner.Name, "iTunes Owner")
+	wantString(t, "owner email", owner.Email, "itunes@example.com")
+	wantString(t, "owner namespace", owner.XMLName.Space, NamespaceItunes)
+	const googleplay = "http://www.google.com/schemas/play-podcasts/1.0"
+	if got := rss.Channel.Extensions[xml.Name{Space: googleplay, Local: "owner"}]; len(got) != 2 {
+		t.Errorf("googleplay:owner extensions %q, want both", got)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "1800", want: 30 * time.Minute},
+		{in: "2:30", want: 150 * time.Second},
+		{in: "2:30.5", want: 150*time.Second + 500*time.Millisecond},
+		{in: "0:00.25", want: 250 * time.Millisecond},
+		{in: "1:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{in: "01:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{in: "1:00:00:00", want: 24 * time.Hour},
+		{in: " 45 ", want: 45 * time.Second},
+		{in: "PT1H2M", want: time.Hour + 2*time.Minute},
+		{in: "PT1.5S", want: 1500 * time.Millisecond},
+		{in: "P1DT2H", want: 26 * time.Hour},
+		{in: "1:2:3:4:5", wantErr: true},
+		{in: "1:xx", wantErr: true},
+		{in: "2:30.x", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "PT", wantErr: true},
+		{in: "P1Y", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDuration(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Durations with more fields than babylon used to index past its end and
+// panic.
+func TestParseDurationTooManyFields(t *testing.T) {
+	for _, ds := range []string{"1:2:3:4:5", "0:0:0:0:0:0", "1:2:3:4:5.5", strings.Repeat("1:", 20) + "1"} {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("ParseDuration(%q) panicked: %v", ds, r)
+				}
+			}()
+			if d, err := ParseDuration(ds); err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want error", ds, d)
+			}
+		}()
+	}
+}
@@ -0,0 +1,271 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Elements from the Podcast Index namespace, see
+// https://podcastindex.org/namespace/1.0
+
+// The URI the Podcast Index namespace was first published under, which many
+// feeds still declare. The package's decoders treat it as NamespacePodcast.
+const legacyNamespacePodcast = "https://github.com/Podcastindex-org/podcast-namespace/blob/main/docs/1.0.md"
+
+// canonicalNamespaces passes through the tokens from an xml.Decoder, changing
+// element and attribute names in legacyNamespacePodcast to NamespacePodcast.
+type canonicalNamespaces struct {
+	d *xml.Decoder
+}
+
+// newNamespaceDecoder returns an xml.Decoder for r which treats
+// legacyNamespacePodcast as NamespacePodcast.
+func newNamespaceDecoder(r io.Reader) *xml.Decoder {
+	return xml.NewTokenDecoder(canonicalNamespaces{d: xml.NewDecoder(r)})
+}
+
+func (cn canonicalNamespaces) Token() (xml.Token, error) {
+	tok, err := cn.d.Token()
+	switch t := tok.(type) {
+	case xml.StartElement:
+		t.Name = canonicalName(t.Name)
+		if len(t.Attr) > 0 {
+			attrs := make([]xml.Attr, len(t.Attr))
+			for i, attr := range t.Attr {
+				attrs[i] = attr
+				attrs[i].Name = canonicalName(attr.Name)
+			}
+			t.Attr = attrs
+		}
+		tok = t
+	case xml.EndElement:
+		t.Name = canonicalName(t.Name)
+		tok = t
+	}
+	return tok, err
+}
+
+func canonicalName(name xml.Name) xml.Name {
+	if name.Space == legacyNamespacePodcast {
+		name.Space = NamespacePodcast
+	}
+	return name
+}
+
+// Transcript links to a transcript or closed captions file for an episode.
+type Transcript struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Language string `xml:"language,attr,omitempty"`
+	Rel      string `xml:"rel,attr,omitempty"`
+}
+
+// Chapters links to a chapters file for an episode.
+type Chapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// Soundbite marks a section of an episode which can be used as a preview.
+// StartTime and Duration are in seconds.
+type Soundbite struct {
+	StartTime float64 `xml:"startTime,attr"`
+	Duration  float64 `xml:"duration,attr"`
+	Title     string  `xml:",chardata"`
+}
+
+// Person is someone involved in making a podcast or episode.
+type Person struct {
+	Name  string `xml:",chardata"`
+	Role  string `xml:"role,attr,omitempty"`
+	Group string `xml:"group,attr,omitempty"`
+	Img   string `xml:"img,attr,omitempty"`
+	Href  string `xml:"href,attr,omitempty"`
+}
+
+// Locked tells other podcast platforms whether they may import the feed.
+type Locked struct {
+	Owner string `xml:"owner,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+// Funding links to a page where listeners can support the podcast.
+type Funding struct {
+	URL     string `xml:"url,attr"`
+	Message string `xml:",chardata"`
+}
+
+// UnmarshalXML decodes the funding element. The message is normally the
+// element's text, but some feeds put it in a message attribute instead.
+func (f *Funding) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	type funding Funding
+	aux := struct {
+		*funding
+		MessageAttr string `xml:"message,attr"`
+	}{funding: (*funding)(f)}
+	err := dec.DecodeElement(&aux, &start)
+	if err != nil {
+		return err
+	}
+	f.Message = strings.TrimSpace(firstNonEmpty(f.Message, aux.MessageAttr))
+	return nil
+}
+
+// AlternateEnclosure is another version of an episode's media, such as a
+// different codec or bitrate, or a video version of an audio episode.
+// Bitrate is in bits per second.
+type AlternateEnclosure struct {
+	Type      string             `xml:"type,attr"`
+	Length    int64              `xml:"length,attr,omitempty"`
+	Bitrate   float64            `xml:"bitrate,attr,omitempty"`
+	Height    int                `xml:"height,attr,omitempty"`
+	Lang      string             `xml:"lang,attr,omitempty"`
+	Title     string             `xml:"title,attr,omitempty"`
+	Rel       string             `xml:"rel,attr,omitempty"`
+	Codecs    string             `xml:"codecs,attr,omitempty"`
+	Default   bool               `xml:"default,attr,omitempty"`
+	Integrity *Integrity         `xml:"https://podcastindex.org/namespace/1.0 integrity,omitempty"`
+	Sources   []*AlternateSource `xml:"https://podcastindex.org/namespace/1.0 source,omitempty"`
+}
+
+// AlternateSource is a URI an alternate enclosure can be fetched from. It
+// isn't necessarily HTTP; IPFS and magnet URIs are also used.
+type AlternateSource struct {
+	URI         string `xml:"uri,attr"`
+	ContentType string `xml:"contentType,attr,omitempty"`
+}
+
+// Enclosure returns an enclosure for the first HTTP or HTTPS source of the
+// alternate enclosure, or nil if it doesn't have one.
+func (ae *AlternateEnclosure) Enclosure() *Enclosure {
+	for _, src := range ae.Sources {
+		u, err := url.Parse(strings.TrimSpace(src.URI))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		mimetype := ae.Type
+		if src.ContentType != "" {
+			mimetype = src.ContentType
+		}
+		return &Enclosure{Integrity: ae.Integrity, Length: ae.Length, MIMEType: mimetype, URL: u.String()}
+	}
+	return nil
+}
+
+func (ae *AlternateEnclosure) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	type alternateEnclosureXML struct {
+		Type      string             `xml:"type,attr"`
+		Length    int64              `xml:"length,attr,omitempty"`
+		Bitrate   float64            `xml:"bitrate,attr,omitempty"`
+		Height    int                `xml:"height,attr,omitempty"`
+		Lang      string             `xml:"lang,attr,omitempty"`
+		Title     string             `xml:"title,attr,omitempty"`
+		Rel       string             `xml:"rel,attr,omitempty"`
+		Codecs    string             `xml:"codecs,attr,omitempty"`
+		Default   bool               `xml:"default,attr,omitempty"`
+		Integrity *Integrity         `xml:"podcast:integrity,omitempty"`
+		Sources   []*AlternateSource `xml:"podcast:source,omitempty"`
+	}
+	return enc.EncodeElement(alternateEnclosureXML(*ae), start)
+}
+
+// EnclosureOfType returns an enclosure for the item with the given MIME type,
+// or nil if there isn't one. The item's main enclosure is used if it has the
+// type, otherwise its alternate enclosures are searched. If several alternate
+// enclosures have the type, the one marked as the default is preferred,
+// followed by the one with the highest bitrate. MIME type parameters are
+// ignored when comparing types.
+func (item *Item) EnclosureOfType(mimetype string) *Enclosure {
+	if item.Enclosure != nil && sameMediaType(item.Enclosure.MIMEType, mimetype) {
+		return item.Enclosure
+	}
+	var best *AlternateEnclosure
+	var bestEnc *Enclosure
+	for _, ae := range item.AlternateEnclosures {
+		if !sameMediaType(ae.Type, mimetype) {
+			continue
+		}
+		enc := ae.Enclosure()
+		if enc == nil {
+			continue
+		}
+		if best == nil || (ae.Default && !best.Default) ||
+			(ae.Default == best.Default && ae.Bitrate > best.Bitrate) {
+			best, bestEnc = ae, enc
+		}
+	}
+	return bestEnc
+}
+
+// sameMediaType reports whether two MIME types are the same, ignoring any
+// parameters.
+func sameMediaType(a string, b string) bool {
+	ma, _, err := mime.ParseMediaType(a)
+	if err != nil {
+		return false
+	}
+	mb, _, err := mime.ParseMediaType(b)
+	if err != nil {
+		return false
+	}
+	return ma == mb
+}
+
+// IsHost reports whether the person is a host. Per the namespace, a person
+// without a role is a host.
+func (p *Person) IsHost() bool {
+	role := strings.TrimSpace(p.Role)
+	return role == "" || strings.EqualFold(role, "host")
+}
+
+// IsGuest reports whether the person is a guest.
+func (p *Person) IsGuest() bool {
+	return strings.EqualFold(strings.TrimSpace(p.Role), "guest") ||
+		strings.EqualFold(strings.TrimSpace(p.Group), "guest")
+}
+
+// Hosts returns the channel-level people who are hosts.
+func (ch *Channel) Hosts() []*Person {
+	var hosts []*Person
+	for _, p := range ch.Persons {
+		if p.IsHost() {
+			hosts = append(hosts, p)
+		}
+	}
+	return hosts
+}
+
+// Guests returns the guests listed for the channel and its items, in order of
+// appearance. A guest who appears in several episodes is only listed once.
+func (ch *Channel) Guests() []*Person {
+	var guests []*Person
+	seen := make(map[string]bool)
+	add := func(persons []*Person) {
+		for _, p := range persons {
+			key := strings.TrimSpace(p.Name) + "\t" + strings.TrimSpace(p.Href)
+			if p.IsGuest() && !seen[key] {
+				seen[key] = true
+				guests = append(guests, p)
+			}
+		}
+	}
+	add(ch.Persons)
+	for _, item := range ch.Item {
+		add(item.Persons)
+	}
+	return guests
+}
+
+// Guests returns the people listed as guests on the episode.
+func (item *Item) Guests() []*Person {
+	var guests []*Person
+	for _, p := range item.Persons {
+		if p.IsGuest() {
+			guests = append(guests, p)
+		}
+	}
+	return guests
+}
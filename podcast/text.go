@@ -0,0 +1,84 @@
+package podcast
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// StripHTML returns the text content of an HTML fragment, with tags removed
+// and entities decoded. The contents of script and style elements are
+// dropped. Block elements and line breaks are replaced by spaces, so that the
+// words either side don't run together, and runs of whitespace are collapsed
+// into single spaces.
+func StripHTML(s string) string {
+	var sb strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(sb.String()), " ")
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			if isRawTextElement(string(name)) {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+			}
+			if !inlineElements[string(name)] {
+				sb.WriteByte(' ')
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+			}
+		}
+	}
+}
+
+// Elements which don't separate words
+var inlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "cite": true,
+	"code": true, "data": true, "dfn": true, "em": true, "i": true, "kbd": true,
+	"mark": true, "q": true, "s": true, "samp": true, "small": true,
+	"span": true, "strong": true, "sub": true, "sup": true, "time": true,
+	"u": true, "var": true,
+}
+
+func isRawTextElement(name string) bool {
+	return name == "script" || name == "style"
+}
+
+// PlainDescription returns the item's description as plain text, with any
+// HTML removed. It returns an empty string for a nil item.
+func (item *Item) PlainDescription() string {
+	if item == nil {
+		return ""
+	}
+	return StripHTML(item.Description)
+}
+
+// PlainDescription returns the channel's description as plain text, with any
+// HTML removed. It returns an empty string for a nil channel.
+func (ch *Channel) PlainDescription() string {
+	if ch == nil {
+		return ""
+	}
+	return StripHTML(ch.Description)
+}
+
+// WordCount returns the approximate number of words in the descriptions of
+// the channel's items, including their content:encoded show notes. HTML is
+// stripped before counting.
+func (ch *Channel) WordCount() int {
+	n := 0
+	for _, item := range ch.Item {
+		n += len(strings.Fields(StripHTML(item.Description)))
+		n += len(strings.Fields(StripHTML(item.ContentEncoded)))
+	}
+	return n
+}
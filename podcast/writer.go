@@ -0,0 +1,308 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Namespaces declared on the root element of generated feeds.
+const (
+	NamespaceItunes  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	NamespaceContent = "http://purl.org/rss/1.0/modules/content/"
+	NamespaceAtom    = "http://www.w3.org/2005/Atom"
+	NamespacePodcast = "https://podcastindex.org/namespace/1.0"
+	NamespaceSy      = "http://purl.org/rss/1.0/modules/syndication/"
+)
+
+// Writer writes RSS 2.0 documents to an output stream.
+type Writer struct {
+	w      io.Writer
+	Indent string
+}
+
+// NewWriter returns a Writer which writes to w, indenting with two spaces.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, Indent: "  "}
+}
+
+// Write writes the XML header and the complete feed document.
+func (w *Writer) Write(r *RSS) error {
+	if _, err := io.WriteString(w.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w.w)
+	enc.Indent("", w.Indent)
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n")
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// WriteTo streams the feed to w as an RSS 2.0 XML document, returning the
+// number of bytes written.
+func (r *RSS) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := NewWriter(cw).Write(r)
+	return cw.n, err
+}
+
+// Marshal returns the feed as an RSS 2.0 XML document.
+func (r *RSS) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := r.WriteTo(&buf)
+	return buf.Bytes(), err
+}
+
+// MarshalXML writes the <rss> element, declaring the namespaces used by the
+// channel and item elements.
+func (r *RSS) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "rss"}
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "version"}, Value: "2.0"},
+		{Name: xml.Name{Local: "xmlns:itunes"}, Value: NamespaceItunes},
+		{Name: xml.Name{Local: "xmlns:content"}, Value: NamespaceContent},
+		{Name: xml.Name{Local: "xmlns:atom"}, Value: NamespaceAtom},
+		{Name: xml.Name{Local: "xmlns:podcast"}, Value: NamespacePodcast},
+		{Name: xml.Name{Local: "xmlns:media"}, Value: NamespaceMedia},
+		{Name: xml.Name{Local: "xmlns:sy"}, Value: NamespaceSy},
+	}
+	w := elementWriter{enc: enc}
+	w.start(start)
+	w.element("channel", r.Channel)
+	w.end(start)
+	return w.err
+}
+
+// elementWriter writes a sequence of child elements, skipping empty values and
+// remembering the first error encountered.
+type elementWriter struct {
+	enc *xml.Encoder
+	err error
+}
+
+func (w *elementWriter) start(start xml.StartElement) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(start)
+	}
+}
+
+func (w *elementWriter) end(start xml.StartElement) {
+	if w.err == nil {
+		w.err = w.enc.EncodeToken(start.End())
+	}
+}
+
+func (w *elementWriter) text(name string, value string) {
+	if value != "" {
+		w.element(name, value)
+	}
+}
+
+func (w *elementWriter) element(name string, v interface{}) {
+	if w.err == nil {
+		w.err = w.enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+}
+
+func (ch *Channel) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	w := elementWriter{enc: enc}
+	w.start(start)
+	w.text("title", ch.Title)
+	w.text("link", ch.Link)
+	for _, link := range ch.AtomLink {
+		w.element("atom:link", link)
+	}
+	if ch.NextPageURL != "" && !ch.hasAtomLink("next") {
+		w.element("atom:link", &AtomLink{Href: ch.NextPageURL, Rel: "next"})
+	}
+	w.text("description", ch.Description)
+	w.text("language", ch.Language)
+	w.text("copyright", ch.Copyright)
+	w.text("managingEditor", ch.ManagingEditor)
+	w.text("webMaster", ch.WebMaster)
+	w.text("pubDate", ch.PubString)
+	if ch.LastBuild != nil {
+		w.element("lastBuildDate", ch.LastBuild)
+	}
+	if ch.Cloud != nil {
+		w.element("cloud", ch.Cloud)
+	}
+	if ch.TTL > 0 {
+		w.element("ttl", ch.TTL)
+	}
+	w.text("sy:updatePeriod", ch.UpdatePeriod)
+	if ch.UpdateFrequency > 0 {
+		w.element("sy:updateFrequency", ch.UpdateFrequency)
+	}
+	if len(ch.SkipHours) > 0 {
+		w.element("skipHours", struct {
+			Hour []int `xml:"hour"`
+		}{ch.SkipHours})
+	}
+	if len(ch.SkipDays) > 0 {
+		w.element("skipDays", struct {
+			Day []string `xml:"day"`
+		}{ch.SkipDays})
+	}
+	w.text("itunes:author", ch.Author)
+	w.text("itunes:subtitle", ch.Subtitle)
+	w.text("itunes:summary", ch.Summary)
+	w.text("itunes:explicit", ch.Explicit)
+	if ch.Image != nil && ch.Image.URL != "" {
+		w.element("image", struct {
+			URL   string `xml:"url"`
+			Title string `xml:"title,omitempty"`
+			Link  string `xml:"link,omitempty"`
+		}{ch.Image.URL, ch.Image.Title, ch.Image.Link})
+	}
+	if ch.Image != nil && ch.Image.AttrHref != "" {
+		w.element("itunes:image", ch.Image)
+	}
+	if ch.Owner != nil {
+		w.element("itunes:owner", ch.Owner)
+	}
+	for _, cat := range ch.Category {
+		w.element("itunes:category", cat)
+	}
+	if ch.Locked != nil {
+		w.element("podcast:locked", ch.Locked)
+	}
+	w.text("podcast:guid", ch.PodcastGUID)
+	w.text("podcast:medium", ch.Medium)
+	for _, f := range ch.Funding {
+		w.element("podcast:funding", f)
+	}
+	for _, person := range ch.Persons {
+		w.element("podcast:person", person)
+	}
+	for _, item := range ch.Item {
+		w.element("item", item)
+	}
+	w.end(start)
+	return w.err
+}
+
+func (ch *Channel) hasAtomLink(rel string) bool {
+	for _, link := range ch.AtomLink {
+		if link.Rel == rel {
+			return true
+		}
+	}
+	return false
+}
+
+func (item *Item) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	w := elementWriter{enc: enc}
+	w.start(start)
+	w.text("title", item.Title)
+	w.text("link", item.Link)
+	w.text("description", item.Description)
+	w.text("content:encoded", item.ContentEncoded)
+	w.text("itunes:author", item.Author)
+	w.text("category", item.Category)
+	w.text("comments", item.Comments)
+	if item.Guid != nil {
+		w.element("guid", item.Guid)
+	}
+	w.element("pubDate", &item.PubDate)
+	if item.Enclosure != nil {
+		w.element("enclosure", item.Enclosure)
+	}
+	for _, ae := range item.AlternateEnclosures {
+		w.element("podcast:alternateEnclosure", ae)
+	}
+	for _, mc := range item.MediaContent {
+		w.element("media:content", mc)
+	}
+	if item.Source != nil {
+		w.element("source", item.Source)
+	}
+	w.element("itunes:duration", &item.Duration)
+	w.text("itunes:explicit", item.Explicit)
+	if len(item.Keywords) > 0 {
+		w.element("itunes:keywords", &item.Keywords)
+	}
+	if item.Season > 0 {
+		w.element("itunes:season", item.Season)
+	}
+	if item.Episode > 0 {
+		w.element("itunes:episode", item.Episode)
+	}
+	for _, tr := range item.Transcripts {
+		w.element("podcast:transcript", tr)
+	}
+	if item.Chapters != nil {
+		w.element("podcast:chapters", item.Chapters)
+	}
+	for _, sb := range item.Soundbites {
+		w.element("podcast:soundbite", sb)
+	}
+	for _, person := range item.Persons {
+		w.element("podcast:person", person)
+	}
+	w.end(start)
+	return w.err
+}
+
+func (img *Image) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{{Name: xml.Name{Local: "href"}, Value: img.AttrHref}}
+	w := elementWriter{enc: enc}
+	w.start(start)
+	w.end(start)
+	return w.err
+}
+
+func (cat *Category) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{{Name: xml.Name{Local: "text"}, Value: cat.AttrText}}
+	w := elementWriter{enc: enc}
+	w.start(start)
+	if cat.Subcategory != nil {
+		w.element(start.Name.Local, cat.Subcategory)
+	}
+	w.end(start)
+	return w.err
+}
+
+func (own *Owner) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	w := elementWriter{enc: enc}
+	w.start(start)
+	w.text("itunes:name", own.Name)
+	w.text("itunes:email", own.Email)
+	w.end(start)
+	return w.err
+}
+
+// MarshalXML writes the timestamp in RFC 1123 format, or nothing at all if
+// the timestamp is zero.
+func (ts *Timestamp) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	if ts.IsZero() {
+		return nil
+	}
+	return enc.EncodeElement(ts.Format(time.RFC1123Z), start)
+}
+
+// MarshalXML writes the duration as H:MM:SS, or nothing at all if the
+// duration is zero.
+func (dur *Duration) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	if *dur == 0 {
+		return nil
+	}
+	return enc.EncodeElement(dur.String(), start)
+}
@@ -0,0 +1,147 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// normalize clears the parts of a parsed feed which the writer doesn't
+// preserve, so that a feed can be compared with the result of writing and
+// parsing it again.
+func normalize(rss *RSS) {
+	// The writer declares its own set of namespaces, and doesn't write
+	// elements it doesn't know about
+	rss.AttrXmlnsItunes = ""
+	rss.Namespaces = nil
+	rss.Channel.Extensions = nil
+	// Categories from other namespaces, such as media:category, are
+	// written as itunes:category
+	var clearNames func(cat *Category)
+	clearNames = func(cat *Category) {
+		for ; cat != nil; cat = cat.Subcategory {
+			cat.XMLName = xml.Name{}
+		}
+	}
+	for _, cat := range rss.Channel.Category {
+		clearNames(cat)
+	}
+	for _, item := range rss.Channel.Item {
+		item.Extensions = nil
+		// Durations are written to the nearest second
+		item.Duration = Duration(time.Duration(item.Duration).Round(time.Second))
+	}
+}
+
+// Fixtures which are meant not to parse, so can't be written out again
+var unparseable = map[string]bool{
+	"badpubdate.xml": true,
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(testdata("*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rss, err := ParseFile(file)
+			if unparseable[filepath.Base(file)] {
+				if err == nil {
+					t.Fatalf("parsed, but it's listed as unparseable")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("can't parse: %v", err)
+			}
+			out, err := rss.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			again, err := NewDecoder(bytes.NewReader(out)).Decode()
+			if err != nil {
+				t.Fatalf("can't parse written feed: %v\n%s", err, out)
+			}
+			normalize(rss)
+			normalize(again)
+			if !reflect.DeepEqual(rss.Channel, again.Channel) {
+				t.Errorf("written feed differs from original:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestWriterGolden(t *testing.T) {
+	rss, err := ParseFile(testdata("full.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := rss.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+	}
+	golden := testdata("full.xml.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output differs from %s, run go test -update to see the change:\n%s", golden, buf.Bytes())
+	}
+}
+
+// TestWriterLosses pins down what the writer is known not to preserve, so
+// that any change to it is deliberate.
+func TestWriterLosses(t *testing.T) {
+	const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:ext="http://example.com/ext">
+  <channel>
+    <title>Losses</title>
+    <author>rss@example.com</author>
+    <ext:rating>5 stars</ext:rating>
+  </channel>
+</rss>`
+	rss, err := NewDecoder(strings.NewReader(feed)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rss.Channel.Extensions.Get("http://example.com/ext", "rating"); got != "5 stars" {
+		t.Fatalf("extension = %q, want %q", got, "5 stars")
+	}
+	if got := rss.Namespaces["ext"]; got != "http://example.com/ext" {
+		t.Fatalf("xmlns:ext = %q, want http://example.com/ext", got)
+	}
+	out, err := rss.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("extensions and namespaces", func(t *testing.T) {
+		if bytes.Contains(out, []byte("rating")) || bytes.Contains(out, []byte("http://example.com/ext")) {
+			t.Errorf("extension written:\n%s", out)
+		}
+	})
+	t.Run("author", func(t *testing.T) {
+		if !bytes.Contains(out, []byte("<itunes:author>rss@example.com</itunes:author>")) ||
+			bytes.Contains(out, []byte("<author>")) {
+			t.Errorf("author not written as itunes:author:\n%s", out)
+		}
+	})
+}